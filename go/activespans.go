@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// activeSpansProcessor counts spans that have started but not yet ended and
+// reports the count as otel.active.spans. A value that keeps climbing means
+// spans are started without End being called. Starting and ending a span
+// only touches an atomic counter; the value is read when metrics are
+// collected. Shutdown unregisters the callback so a shut-down tracer
+// provider stops reporting.
+type activeSpansProcessor struct {
+	active atomic.Int64
+	reg    metric.Registration
+}
+
+func newActiveSpansProcessor(logger *slog.Logger) *activeSpansProcessor {
+	p := &activeSpansProcessor{}
+	// Like the exporter metrics, this is registered on the global meter, which
+	// delegates to the SDK meter provider once it is installed.
+	meter := otel.Meter(selfScopeName)
+	counter, err := meter.Int64ObservableUpDownCounter("otel.active.spans",
+		metric.WithDescription("Spans started but not yet ended."),
+		metric.WithUnit("{span}"),
+	)
+	if err == nil {
+		p.reg, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			o.ObserveInt64(counter, p.active.Load())
+			return nil
+		}, counter)
+	}
+	if err != nil {
+		logger.Error("failed to create active spans counter", "error", err)
+	}
+	return p
+}
+
+func (p *activeSpansProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) { p.active.Add(1) }
+func (p *activeSpansProcessor) OnEnd(sdktrace.ReadOnlySpan)                     { p.active.Add(-1) }
+func (p *activeSpansProcessor) ForceFlush(context.Context) error                { return nil }
+
+func (p *activeSpansProcessor) Shutdown(context.Context) error {
+	if p.reg == nil {
+		return nil
+	}
+	return p.reg.Unregister()
+}
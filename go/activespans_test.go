@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// activeSpans collects reader and returns the otel.active.spans value, or
+// false if it was not reported.
+func activeSpans(t *testing.T, reader sdkmetric.Reader) (int64, bool) {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "otel.active.spans" {
+				continue
+			}
+			sum := m.Data.(metricdata.Sum[int64])
+			if len(sum.DataPoints) == 0 {
+				return 0, false
+			}
+			return sum.DataPoints[0].Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestActiveSpansProcessorUnregistersOnShutdown(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(newActiveSpansProcessor(slog.Default())))
+	_, span := tp.Tracer("test").Start(context.Background(), "open")
+	if got, ok := activeSpans(t, reader); !ok || got != 1 {
+		t.Fatalf("otel.active.spans = %d (reported %v), want 1", got, ok)
+	}
+	span.End()
+	if got, ok := activeSpans(t, reader); !ok || got != 0 {
+		t.Fatalf("otel.active.spans = %d (reported %v) after End, want 0", got, ok)
+	}
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := activeSpans(t, reader); ok {
+		t.Errorf("otel.active.spans = %d still reported after Shutdown", got)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// adaptiveWindow is the number of one-second buckets the adaptive sampler
+// measures rates over.
+const adaptiveWindow = 10
+
+// WithTargetSpanRate samples new traces with a probability that keeps the
+// number of sampled spans near perSecond, so trace volume stays bounded
+// during traffic spikes. Every second the sampler measures how many root
+// spans start per second, the higher of the last second and the last 10
+// seconds' average, and how many sampled spans each sampled trace produces
+// in this process, and sets the probability for new root spans to the
+// target divided by their product (at most 1).
+//
+// The rate is approximate. The first second after setup is not limited. A
+// spike is throttled after about a second, while after a drop in traffic the
+// probability rises over up to 10 seconds, undershooting the target
+// meanwhile. Spans are counted when they start, in this process only.
+// Spans continuing a remote trace follow the caller's decision and are not
+// throttled, but they count against the budget; so do traces forced by a
+// sampling hint or WithTraceStateSampling.
+func WithTargetSpanRate(perSecond float64) Option {
+	return func(c *config) {
+		c.targetSpanRate = perSecond
+	}
+}
+
+// adaptiveBucket counts the spans seen in one second.
+type adaptiveBucket struct {
+	roots        atomic.Int64
+	sampledRoots atomic.Int64
+	sampledSpans atomic.Int64
+}
+
+// adaptiveSampler decides root spans with a probability adjusted towards a
+// target span rate, and defers to base for spans with a parent. Spans are
+// counted without locking; only the rotation of the buckets, once a second,
+// takes mu.
+type adaptiveSampler struct {
+	base     sdktrace.Sampler
+	target   float64
+	annotate bool
+
+	buckets     [adaptiveWindow]adaptiveBucket
+	current     atomic.Int32
+	probability atomic.Uint64 // math.Float64bits of the probability
+	// nextAdvance is the time, in Unix nanoseconds, after which the next
+	// root span rotates the buckets.
+	nextAdvance atomic.Int64
+
+	mu           sync.Mutex
+	filled       int // completed buckets, up to adaptiveWindow-1
+	bucketStart  time.Time
+	spansPerRoot float64
+}
+
+func newAdaptiveSampler(base sdktrace.Sampler, target float64, annotate bool) *adaptiveSampler {
+	s := &adaptiveSampler{
+		base:         base,
+		target:       target,
+		annotate:     annotate,
+		bucketStart:  time.Now(),
+		spansPerRoot: 1,
+	}
+	s.probability.Store(math.Float64bits(1))
+	s.nextAdvance.Store(s.bucketStart.Add(time.Second).UnixNano())
+	return s
+}
+
+func (s *adaptiveSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		r := s.base.ShouldSample(p)
+		if r.Decision == sdktrace.RecordAndSample {
+			s.buckets[s.current.Load()].sampledSpans.Add(1)
+		}
+		return r
+	}
+
+	if now := time.Now(); now.UnixNano() >= s.nextAdvance.Load() {
+		s.mu.Lock()
+		s.advance(now)
+		s.mu.Unlock()
+	}
+	b := &s.buckets[s.current.Load()]
+	b.roots.Add(1)
+	if !sampledByRatio(p.TraceID, math.Float64frombits(s.probability.Load())) {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+	b.sampledRoots.Add(1)
+	b.sampledSpans.Add(1)
+	return withReason(sdktrace.SamplingResult{Decision: sdktrace.RecordAndSample}, s.annotate, "adaptive")
+}
+
+// advance moves to the bucket for now and, once a second has passed,
+// recomputes the probability from the completed buckets. s.mu must be held.
+// Spans counted concurrently may land in the bucket just completed after it
+// was summed, which the rate tolerates.
+func (s *adaptiveSampler) advance(now time.Time) {
+	elapsed := int(now.Sub(s.bucketStart) / time.Second)
+	if elapsed <= 0 {
+		return
+	}
+	s.bucketStart = s.bucketStart.Add(time.Duration(elapsed) * time.Second)
+	s.nextAdvance.Store(s.bucketStart.Add(time.Second).UnixNano())
+	current := int(s.current.Load())
+	var lastRoots int64
+	if elapsed == 1 {
+		lastRoots = s.buckets[current].roots.Load()
+	}
+	for range min(elapsed, adaptiveWindow) {
+		current = (current + 1) % adaptiveWindow
+		b := &s.buckets[current]
+		b.roots.Store(0)
+		b.sampledRoots.Store(0)
+		b.sampledSpans.Store(0)
+	}
+	s.current.Store(int32(current))
+	s.filled = min(s.filled+elapsed, adaptiveWindow-1)
+
+	var roots, sampledRoots, sampledSpans int64
+	for i := range s.buckets {
+		if i != current {
+			b := &s.buckets[i]
+			roots += b.roots.Load()
+			sampledRoots += b.sampledRoots.Load()
+			sampledSpans += b.sampledSpans.Load()
+		}
+	}
+	if sampledRoots > 0 {
+		s.spansPerRoot = float64(sampledSpans) / float64(sampledRoots)
+	}
+	// React to spikes within a second, but to drops only as they show in
+	// the window average.
+	rootRate := max(float64(roots)/float64(s.filled), float64(lastRoots))
+	if rootRate == 0 {
+		s.probability.Store(math.Float64bits(1))
+		return
+	}
+	s.probability.Store(math.Float64bits(min(1, s.target/(rootRate*s.spansPerRoot))))
+}
+
+func (s *adaptiveSampler) Description() string {
+	return "AdaptiveSampler{target=" + strconv.FormatFloat(s.target, 'g', -1, 64) + "/s," + s.base.Description() + "}"
+}
+
+// sampledByRatio makes the same decision as sdktrace.TraceIDRatioBased for
+// probability, so the decision is consistent for a trace ID.
+func sampledByRatio(id trace.TraceID, probability float64) bool {
+	bound := uint64(probability * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:16])>>1 < bound
+}
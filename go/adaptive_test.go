@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampleSpans asks s about roots root spans and, for each sampled one,
+// children child spans, and returns the number of sampled roots.
+func sampleSpans(s *adaptiveSampler, roots, children int) int {
+	gen := sdktrace.NewTracerProvider().Tracer("test")
+	sampled := 0
+	for range roots {
+		_, span := gen.Start(context.Background(), "root")
+		tid := span.SpanContext().TraceID()
+		if s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: tid}).Decision != sdktrace.RecordAndSample {
+			continue
+		}
+		sampled++
+		parent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    tid,
+			SpanID:     span.SpanContext().SpanID(),
+			TraceFlags: trace.FlagsSampled,
+		}))
+		for range children {
+			s.ShouldSample(sdktrace.SamplingParameters{ParentContext: parent, TraceID: tid})
+		}
+	}
+	return sampled
+}
+
+func TestAdaptiveSamplerThrottles(t *testing.T) {
+	s := newAdaptiveSampler(sdktrace.ParentBased(sdktrace.AlwaysSample()), 100, false)
+	// Rotate the buckets only when the test says so.
+	s.nextAdvance.Store(math.MaxInt64)
+	advance := func() {
+		s.mu.Lock()
+		s.advance(s.bucketStart.Add(time.Second))
+		s.mu.Unlock()
+	}
+
+	// The first second is not limited.
+	if got := sampleSpans(s, 1000, 4); got != 1000 {
+		t.Fatalf("sampled %d roots in the first second, want all 1000", got)
+	}
+	advance()
+	// 1000 roots/s of 5 spans each against 100 spans/s.
+	if got, want := math.Float64frombits(s.probability.Load()), 0.02; math.Abs(got-want) > 1e-9 {
+		t.Errorf("probability = %v, want %v", got, want)
+	}
+
+	sampled := sampleSpans(s, 1000, 4)
+	if sampled < 5 || sampled > 50 {
+		t.Errorf("sampled %d of 1000 roots, want about 20", sampled)
+	}
+
+	// Without traffic the probability recovers.
+	for range adaptiveWindow {
+		advance()
+	}
+	if got := math.Float64frombits(s.probability.Load()); got != 1 {
+		t.Errorf("probability after an idle window = %v, want 1", got)
+	}
+}
+
+func TestAdaptiveSamplerConcurrent(t *testing.T) {
+	s := newAdaptiveSampler(sdktrace.ParentBased(sdktrace.AlwaysSample()), 50, false)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deadline := time.Now().Add(1200 * time.Millisecond)
+			for time.Now().Before(deadline) {
+				sampleSpans(s, 10, 2)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := math.Float64frombits(s.probability.Load()); got <= 0 || got >= 1 {
+		t.Errorf("probability after a second of load = %v, want throttled", got)
+	}
+}
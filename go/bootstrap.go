@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// bootstrapBufferSize caps how many spans and how many log records made
+// through GetTracer and GetLogger are buffered before setupInstrumentation
+// runs. Telemetry beyond the cap is dropped.
+const bootstrapBufferSize = 512
+
+// bootstrap buffers early telemetry until the real providers are installed,
+// then forwards to them.
+var bootstrap bootstrapState
+
+type bootstrapState struct {
+	mu      sync.Mutex
+	tracer  trace.Tracer
+	handler slog.Handler
+	spans   []*bufferedSpan
+	records []bufferedRecord
+	dropped int
+}
+
+// bufferedRecord is a log record emitted before setup.
+type bufferedRecord struct {
+	handler *bootstrapHandler
+	record  slog.Record
+	span    trace.Span
+}
+
+// install makes tracer and handler the delegates for all bootstrap tracers
+// and loggers and replays the buffered telemetry into them. It returns the
+// number of replayed and dropped items.
+func (b *bootstrapState) install(tracer trace.Tracer, handler slog.Handler) (replayed, dropped int) {
+	b.mu.Lock()
+	spans, records, dropped := b.spans, b.records, b.dropped
+	b.spans, b.records, b.dropped = nil, nil, 0
+	b.tracer, b.handler = tracer, handler
+	b.mu.Unlock()
+
+	// Spans are buffered in start order, so parents are replayed first.
+	for _, s := range spans {
+		s.replay(tracer)
+	}
+	for _, r := range records {
+		ctx := context.Background()
+		if s, ok := r.span.(*bufferedSpan); ok {
+			ctx = trace.ContextWithSpan(ctx, s.delegate())
+		} else if r.span != nil {
+			ctx = trace.ContextWithSpan(ctx, r.span)
+		}
+		h := r.handler.resolve(handler)
+		if h.Enabled(ctx, r.record.Level) {
+			_ = h.Handle(ctx, r.record)
+		}
+	}
+	return len(spans) + len(records), dropped
+}
+
+// bootstrapTracer is the tracer returned by GetTracer before setup.
+type bootstrapTracer struct {
+	embedded.Tracer
+}
+
+func (bootstrapTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	bootstrap.mu.Lock()
+	if tracer := bootstrap.tracer; tracer != nil {
+		bootstrap.mu.Unlock()
+		return tracer.Start(ctx, name, opts...)
+	}
+	defer bootstrap.mu.Unlock()
+
+	if len(bootstrap.spans) >= bootstrapBufferSize {
+		bootstrap.dropped++
+		return ctx, noop.Span{}
+	}
+
+	cfg := trace.NewSpanStartConfig(opts...)
+	start := cfg.Timestamp()
+	if start.IsZero() {
+		start = time.Now()
+	}
+	s := &bufferedSpan{
+		name:   name,
+		parent: trace.SpanFromContext(ctx),
+		opts:   append(slices.Clip(opts), trace.WithTimestamp(start)),
+	}
+	bootstrap.spans = append(bootstrap.spans, s)
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+// bufferedSpan records the calls made on a span started before setup so they
+// can be replayed on a real span. Once replayed it forwards to that span.
+// Its SpanContext is invalid until then, so it cannot be propagated.
+type bufferedSpan struct {
+	embedded.Span
+
+	mu     sync.Mutex
+	name   string
+	parent trace.Span
+	opts   []trace.SpanStartOption
+	ops    []func(trace.Span)
+	ended  bool
+	real   trace.Span
+}
+
+// record runs op on the real span, or buffers it until replay.
+func (s *bufferedSpan) record(op func(trace.Span)) {
+	s.mu.Lock()
+	if real := s.real; real != nil {
+		s.mu.Unlock()
+		op(real)
+		return
+	}
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.ops = append(s.ops, op)
+	}
+}
+
+// replay starts the real span and applies the buffered calls to it.
+func (s *bufferedSpan) replay(tracer trace.Tracer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := context.Background()
+	if p, ok := s.parent.(*bufferedSpan); ok {
+		ctx = trace.ContextWithSpan(ctx, p.delegate())
+	} else if s.parent != nil {
+		ctx = trace.ContextWithSpan(ctx, s.parent)
+	}
+	_, s.real = tracer.Start(ctx, s.name, s.opts...)
+	for _, op := range s.ops {
+		op(s.real)
+	}
+	s.ops = nil
+}
+
+// delegate returns the real span once replayed, or s itself.
+func (s *bufferedSpan) delegate() trace.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.real != nil {
+		return s.real
+	}
+	return s
+}
+
+func (s *bufferedSpan) End(opts ...trace.SpanEndOption) {
+	if cfg := trace.NewSpanEndConfig(opts...); cfg.Timestamp().IsZero() {
+		opts = append(slices.Clip(opts), trace.WithTimestamp(time.Now()))
+	}
+	s.record(func(real trace.Span) { real.End(opts...) })
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+}
+
+func (s *bufferedSpan) AddEvent(name string, opts ...trace.EventOption) {
+	opts = withEventTimestamp(opts)
+	s.record(func(real trace.Span) { real.AddEvent(name, opts...) })
+}
+
+func (s *bufferedSpan) AddLink(link trace.Link) {
+	s.record(func(real trace.Span) { real.AddLink(link) })
+}
+
+func (s *bufferedSpan) RecordError(err error, opts ...trace.EventOption) {
+	opts = withEventTimestamp(opts)
+	s.record(func(real trace.Span) { real.RecordError(err, opts...) })
+}
+
+func (s *bufferedSpan) SetStatus(code codes.Code, description string) {
+	s.record(func(real trace.Span) { real.SetStatus(code, description) })
+}
+
+func (s *bufferedSpan) SetName(name string) {
+	s.record(func(real trace.Span) { real.SetName(name) })
+}
+
+func (s *bufferedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.record(func(real trace.Span) { real.SetAttributes(kv...) })
+}
+
+func (s *bufferedSpan) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.real != nil {
+		return s.real.IsRecording()
+	}
+	return !s.ended
+}
+
+func (s *bufferedSpan) SpanContext() trace.SpanContext {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.real != nil {
+		return s.real.SpanContext()
+	}
+	return trace.SpanContext{}
+}
+
+func (s *bufferedSpan) TracerProvider() trace.TracerProvider {
+	return otel.GetTracerProvider()
+}
+
+// withEventTimestamp pins the event time to now so replayed events keep
+// their original time.
+func withEventTimestamp(opts []trace.EventOption) []trace.EventOption {
+	if cfg := trace.NewEventConfig(opts...); cfg.Timestamp().IsZero() {
+		opts = append(slices.Clip(opts), trace.WithTimestamp(time.Now()))
+	}
+	return opts
+}
+
+// bootstrapHandler is the slog handler behind GetLogger before setup. Each
+// WithAttrs or WithGroup call adds a node referring to its parent, so the
+// same chain can be rebuilt on the real handler.
+type bootstrapHandler struct {
+	parent *bootstrapHandler
+	attrs  []slog.Attr
+	group  string
+
+	mu   sync.Mutex
+	real slog.Handler
+}
+
+// resolve returns the real handler with this node's attrs and groups applied.
+func (h *bootstrapHandler) resolve(handler slog.Handler) slog.Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.real != nil {
+		return h.real
+	}
+	if h.parent != nil {
+		handler = h.parent.resolve(handler)
+	}
+	if h.group != "" {
+		handler = handler.WithGroup(h.group)
+	}
+	if len(h.attrs) > 0 {
+		handler = handler.WithAttrs(h.attrs)
+	}
+	h.real = handler
+	return handler
+}
+
+func (h *bootstrapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	bootstrap.mu.Lock()
+	handler := bootstrap.handler
+	bootstrap.mu.Unlock()
+	if handler != nil {
+		return h.resolve(handler).Enabled(ctx, level)
+	}
+	return true
+}
+
+func (h *bootstrapHandler) Handle(ctx context.Context, r slog.Record) error {
+	bootstrap.mu.Lock()
+	if handler := bootstrap.handler; handler != nil {
+		bootstrap.mu.Unlock()
+		return h.resolve(handler).Handle(ctx, r)
+	}
+	defer bootstrap.mu.Unlock()
+
+	if len(bootstrap.records) >= bootstrapBufferSize {
+		bootstrap.dropped++
+		return nil
+	}
+	bootstrap.records = append(bootstrap.records, bufferedRecord{
+		handler: h,
+		record:  r.Clone(),
+		span:    trace.SpanFromContext(ctx),
+	})
+	return nil
+}
+
+func (h *bootstrapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &bootstrapHandler{parent: h, attrs: attrs}
+}
+
+func (h *bootstrapHandler) WithGroup(name string) slog.Handler {
+	return &bootstrapHandler{parent: h, group: name}
+}
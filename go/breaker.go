@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// errCircuitOpen is returned for exports skipped while the circuit breaker is
+// open. The exporters do not retry it, so the batch is dropped.
+var errCircuitOpen = errors.New("OTLP export skipped: circuit breaker open after repeated failures")
+
+// WithCircuitBreaker stops exporting after failures consecutive failed
+// export requests (network errors, 429 and 5xx responses) and drops telemetry
+// for cooldown instead of retrying against a backend that is down. After the
+// cooldown a single probe request is let through: if it succeeds exports
+// resume, otherwise the breaker stays open for another cooldown. Each signal
+// has its own breaker.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(c *config) {
+		c.breakerFailures = failures
+		c.breakerCooldown = cooldown
+	}
+}
+
+// circuitBreakerTransport fails export requests fast while the backend is
+// considered down.
+type circuitBreakerTransport struct {
+	base      http.RoundTripper
+	signal    string
+	logger    *slog.Logger
+	threshold int
+	cooldown  time.Duration
+	opened    metric.Int64Counter
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreakerTransport(base http.RoundTripper, signal string, logger *slog.Logger, threshold int, cooldown time.Duration) *circuitBreakerTransport {
+	opened, err := otel.Meter(selfScopeName).Int64Counter("otel.exporter.circuit_breaker.opened",
+		metric.WithDescription("Times the export circuit breaker opened after repeated failures."),
+		metric.WithUnit("{open}"),
+	)
+	if err != nil {
+		logger.Error("failed to create circuit breaker counter", "error", err)
+	}
+	return &circuitBreakerTransport{
+		base:      base,
+		signal:    signal,
+		logger:    logger,
+		threshold: threshold,
+		cooldown:  cooldown,
+		opened:    opened,
+	}
+}
+
+func (t *circuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	probe, ok := t.allow()
+	if !ok {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errCircuitOpen
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	t.record(req.Context(), probe, err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+	return resp, err
+}
+
+// allow reports whether a request may be sent, and whether it is the probe
+// sent after the cooldown.
+func (t *circuitBreakerTransport) allow() (probe, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.openUntil.IsZero() {
+		return false, true
+	}
+	if t.probing || time.Now().Before(t.openUntil) {
+		return false, false
+	}
+	t.probing = true
+	return true, true
+}
+
+// record updates the breaker with the outcome of a request.
+func (t *circuitBreakerTransport) record(ctx context.Context, probe, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if probe {
+		t.probing = false
+	}
+	if !failed {
+		if !t.openUntil.IsZero() {
+			t.logger.InfoContext(ctx, "OTLP export circuit breaker closed", "signal", t.signal)
+		}
+		t.failures, t.openUntil = 0, time.Time{}
+		return
+	}
+
+	t.failures++
+	if probe || (t.openUntil.IsZero() && t.failures >= t.threshold) {
+		if !probe {
+			t.logger.WarnContext(ctx, "OTLP export circuit breaker opened, dropping telemetry",
+				"signal", t.signal,
+				"failures", t.failures,
+				"cooldown", t.cooldown)
+			if t.opened != nil {
+				t.opened.Add(ctx, 1, metric.WithAttributes(attribute.String("signal", t.signal)))
+			}
+		}
+		t.openUntil = time.Now().Add(t.cooldown)
+	}
+}
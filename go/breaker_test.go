@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statusTransport answers every request with the status in status and
+// counts the requests; a status of 0 fails them with a network error.
+type statusTransport struct {
+	status   atomic.Int32
+	requests atomic.Int32
+	// block, if set, is waited on before answering.
+	block chan struct{}
+}
+
+func (t *statusTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	if t.block != nil {
+		<-t.block
+	}
+	status := int(t.status.Load())
+	if status == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: status, Header: http.Header{}, Body: http.NoBody}, nil
+}
+
+func TestCircuitBreakerProbeAfterCooldown(t *testing.T) {
+	base := &statusTransport{}
+	base.status.Store(http.StatusServiceUnavailable)
+	var logs syncBuffer
+	breaker := newCircuitBreakerTransport(base, signalTraces, slog.New(slog.NewTextHandler(&logs, nil)), 2, 50*time.Millisecond)
+	send := func() error {
+		req, err := http.NewRequest(http.MethodPost, "http://collector:4318/v1/traces", strings.NewReader("spans"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := breaker.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	send()
+	send()
+	if err := send(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("third request after 2 failures: %v, want errCircuitOpen", err)
+	}
+	if got := base.requests.Load(); got != 2 {
+		t.Fatalf("backend got %d requests, want none while open", got)
+	}
+
+	// The probe after the cooldown fails, so the breaker opens again.
+	time.Sleep(60 * time.Millisecond)
+	send()
+	if got := base.requests.Load(); got != 3 {
+		t.Fatalf("backend got %d requests, want the probe", got)
+	}
+	if err := send(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("request after a failed probe: %v, want errCircuitOpen", err)
+	}
+
+	// While a probe is in flight, other requests still fail fast.
+	time.Sleep(60 * time.Millisecond)
+	base.status.Store(http.StatusOK)
+	base.block = make(chan struct{})
+	probe := make(chan error)
+	go func() { probe <- send() }()
+	eventually(t, "the probe to reach the backend", func() bool { return base.requests.Load() == 4 })
+	if err := send(); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("request during the probe: %v, want errCircuitOpen", err)
+	}
+	close(base.block)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	// The successful probe closes the breaker.
+	if err := send(); err != nil {
+		t.Errorf("request after a successful probe: %v", err)
+	}
+	if got := base.requests.Load(); got != 5 {
+		t.Errorf("backend got %d requests, want 5", got)
+	}
+	if !strings.Contains(logs.String(), "circuit breaker closed") {
+		t.Errorf("closing not logged:\n%s", logs.String())
+	}
+}
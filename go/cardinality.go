@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// WithCardinalityWarning checks every interval how many distinct attribute
+// sets each instrument has and logs a warning through the internal logger,
+// naming the instrument, the first time one exceeds threshold. The counts
+// come from an additional reader on the meter provider, after views, so it
+// roughly doubles the memory used for aggregation. Disabled by default.
+func WithCardinalityWarning(threshold int, interval time.Duration) Option {
+	return func(c *config) {
+		c.cardinalityThreshold = threshold
+		c.cardinalityInterval = interval
+	}
+}
+
+// cardinalityMonitor collects from its own reader and warns about
+// instruments with too many attribute sets.
+type cardinalityMonitor struct {
+	reader    *sdkmetric.ManualReader
+	threshold int
+	interval  time.Duration
+	logger    *slog.Logger
+	warned    map[string]bool
+}
+
+func newCardinalityMonitor(cfg *config) *cardinalityMonitor {
+	return &cardinalityMonitor{
+		reader:    sdkmetric.NewManualReader(),
+		threshold: cfg.cardinalityThreshold,
+		interval:  cfg.cardinalityInterval,
+		logger:    cfg.logger,
+		warned:    map[string]bool{},
+	}
+}
+
+// run checks on every tick until the meter provider shuts the reader down.
+func (m *cardinalityMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var rm metricdata.ResourceMetrics
+		if err := m.reader.Collect(context.Background(), &rm); err != nil {
+			if errors.Is(err, sdkmetric.ErrReaderShutdown) {
+				return
+			}
+			m.logger.Warn("cardinality check failed", "error", err)
+			continue
+		}
+		m.check(&rm)
+	}
+}
+
+func (m *cardinalityMonitor) check(rm *metricdata.ResourceMetrics) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, md := range sm.Metrics {
+			if m.warned[md.Name] {
+				continue
+			}
+			if n := attributeSets(md.Data); n > m.threshold {
+				m.warned[md.Name] = true
+				m.logger.Warn("metric cardinality above threshold",
+					"instrument", md.Name, "scope", sm.Scope.Name, "attribute_sets", n, "threshold", m.threshold)
+			}
+		}
+	}
+}
+
+// attributeSets returns the number of data points, one per attribute set.
+func attributeSets(data metricdata.Aggregation) int {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return len(d.DataPoints)
+	case metricdata.Sum[float64]:
+		return len(d.DataPoints)
+	case metricdata.Gauge[int64]:
+		return len(d.DataPoints)
+	case metricdata.Gauge[float64]:
+		return len(d.DataPoints)
+	case metricdata.Histogram[int64]:
+		return len(d.DataPoints)
+	case metricdata.Histogram[float64]:
+		return len(d.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		return len(d.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		return len(d.DataPoints)
+	case metricdata.Summary:
+		return len(d.DataPoints)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// WithCompressionLevel gzips OTLP export requests at level, from
+// gzip.HuffmanOnly (-2) and gzip.BestSpeed (1) for the least CPU to
+// gzip.BestCompression (9) for the smallest requests; gzip.DefaultCompression
+// (-1) is level 6. Without this option requests are sent uncompressed unless
+// OTEL_EXPORTER_OTLP_COMPRESSION=gzip is set, which uses the default level.
+// The option takes precedence over that variable. Setup fails for a level
+// outside the range.
+func WithCompressionLevel(level int) Option {
+	return func(c *config) {
+		c.compression = true
+		c.compressionLevel = level
+	}
+}
+
+// checkCompressionLevel reports an error if level is not a valid gzip level.
+func checkCompressionLevel(level int) error {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return fmt.Errorf("invalid compression level %d, use %d to %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	return nil
+}
+
+// gzipTransport compresses request bodies at a fixed level. The exporters'
+// own compression must be off, as they only support the default level.
+type gzipTransport struct {
+	base    http.RoundTripper
+	level   int
+	writers *sync.Pool
+}
+
+func newGzipTransport(base http.RoundTripper, level int) gzipTransport {
+	return gzipTransport{
+		base:  base,
+		level: level,
+		writers: &sync.Pool{New: func() any {
+			// The level is checked at setup.
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+	}
+}
+
+func (t gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return t.base.RoundTrip(req)
+	}
+	var buf bytes.Buffer
+	w := t.writers.Get().(*gzip.Writer)
+	defer t.writers.Put(w)
+	w.Reset(&buf)
+	_, err := io.Copy(w, req.Body)
+	req.Body.Close()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body := buf.Bytes()
+	r := req.Clone(req.Context())
+	r.Header.Set("Content-Encoding", "gzip")
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(r)
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the subset of the OpenTelemetry declarative configuration
+// schema that SetupFromConfigFile understands. Decoding rejects any other
+// field, so unsupported settings are reported instead of silently ignored.
+type fileConfig struct {
+	FileFormat     string              `yaml:"file_format"`
+	Disabled       bool                `yaml:"disabled"`
+	Resource       *fileResource       `yaml:"resource"`
+	Propagator     *filePropagator     `yaml:"propagator"`
+	TracerProvider *fileTracerProvider `yaml:"tracer_provider"`
+	MeterProvider  *fileMeterProvider  `yaml:"meter_provider"`
+	LoggerProvider *fileLoggerProvider `yaml:"logger_provider"`
+}
+
+type fileResource struct {
+	SchemaURL  string              `yaml:"schema_url"`
+	Attributes []fileAttributeItem `yaml:"attributes"`
+}
+
+type fileAttributeItem struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+	Type  string `yaml:"type"`
+}
+
+type filePropagator struct {
+	Composite     []fileNamed `yaml:"composite"`
+	CompositeList string      `yaml:"composite_list"`
+}
+
+// fileNamed is an entry written either as a plain name or as a map with a
+// single key, e.g. "tracecontext" or "tracecontext: {}".
+type fileNamed string
+
+func (n *fileNamed) UnmarshalYAML(node *yaml.Node) error {
+	switch {
+	case node.Kind == yaml.ScalarNode:
+		*n = fileNamed(node.Value)
+	case node.Kind == yaml.MappingNode && len(node.Content) == 2:
+		*n = fileNamed(node.Content[0].Value)
+	default:
+		return fmt.Errorf("line %d: expected a name or a single-key map", node.Line)
+	}
+	return nil
+}
+
+type fileTracerProvider struct {
+	Processors []fileProcessor `yaml:"processors"`
+	Sampler    *fileSampler    `yaml:"sampler"`
+}
+
+type fileMeterProvider struct {
+	Readers []fileReader `yaml:"readers"`
+}
+
+type fileLoggerProvider struct {
+	Processors []fileProcessor `yaml:"processors"`
+}
+
+type fileProcessor struct {
+	Batch *fileExporterHolder `yaml:"batch"`
+}
+
+type fileReader struct {
+	Periodic *fileExporterHolder `yaml:"periodic"`
+}
+
+type fileExporterHolder struct {
+	Exporter fileExporter `yaml:"exporter"`
+}
+
+// fileExporter and fileSampler keep their choices as nodes: the schema
+// writes settings without parameters as a key with an empty value, such as
+// "console:", which would decode to a nil pointer.
+type fileExporter struct {
+	OTLPHTTP yaml.Node `yaml:"otlp_http"`
+	Console  yaml.Node `yaml:"console"`
+}
+
+type fileOTLPHTTP struct {
+	Endpoint    string       `yaml:"endpoint"`
+	Headers     []fileHeader `yaml:"headers"`
+	HeadersList string       `yaml:"headers_list"`
+}
+
+type fileHeader struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// fileSampler only accepts the sampler this setup always uses: parent based
+// with an always-on root.
+type fileSampler struct {
+	AlwaysOn    yaml.Node `yaml:"always_on"`
+	ParentBased yaml.Node `yaml:"parent_based"`
+}
+
+// SetupFromConfigFile is Setup configured from an OpenTelemetry declarative
+// configuration file, as named by OTEL_EXPERIMENTAL_CONFIG_FILE:
+//
+//	cleanup, err := SetupFromConfigFile(os.Getenv("OTEL_EXPERIMENTAL_CONFIG_FILE"))
+//
+// Only the parts of the schema this setup supports are honored, see the
+// README; any other field is an error. Values may reference environment
+// variables as ${VAR} or ${VAR:-default}. The service name comes from the
+// service.name resource attribute, then OTEL_SERVICE_NAME, then the program
+// name. opts are applied before the file, so the file wins where both set
+// the same thing.
+func SetupFromConfigFile(path string, opts ...Option) (func(), error) {
+	fc, err := readConfigFile(path)
+	if err != nil {
+		return func() {}, err
+	}
+	if fc.Disabled {
+		return func() {}, nil
+	}
+
+	fileOpts, env, err := fc.options()
+	if err != nil {
+		return func() {}, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if fc.Resource != nil {
+		for _, a := range fc.Resource.Attributes {
+			if a.Name == string(ServiceNameKey) {
+				serviceName = a.Value
+			}
+		}
+	}
+	if serviceName == "" {
+		serviceName = filepath.Base(os.Args[0])
+	}
+
+	getenv := func(key string) string {
+		if v, ok := env[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}
+	return setup(context.Background(), serviceName, getenv, append(slices.Clip(opts), fileOpts...)...)
+}
+
+// readConfigFile reads and decodes path after substituting environment
+// variables.
+func readConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(expandConfigEnv(data)))
+	dec.KnownFields(true)
+	var fc fileConfig
+	if err := dec.Decode(&fc); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	if fc.FileFormat == "" {
+		return nil, fmt.Errorf("config file %s: file_format is required", path)
+	}
+	return &fc, nil
+}
+
+// configEnvRef matches ${VAR}, ${env:VAR} and ${VAR:-default}.
+var configEnvRef = regexp.MustCompile(`\$\{(?:env:)?([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandConfigEnv replaces environment variable references in data.
+func expandConfigEnv(data []byte) []byte {
+	return configEnvRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		m := configEnvRef.FindSubmatch(ref)
+		if v := os.Getenv(string(m[1])); v != "" {
+			return []byte(v)
+		}
+		return m[2]
+	})
+}
+
+// options translates the file into options and environment overrides for
+// setup. A missing provider section disables its signal.
+func (fc *fileConfig) options() ([]Option, map[string]string, error) {
+	var opts []Option
+	env := map[string]string{}
+
+	if r := fc.Resource; r != nil {
+		attrs, err := fileResourceAttributes(r.Attributes)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, func(c *config) {
+			c.resourceAttributes = append(c.resourceAttributes, attrs...)
+			if r.SchemaURL != "" {
+				c.schemaURL = r.SchemaURL
+			}
+		})
+	}
+
+	if p := fc.Propagator; p != nil {
+		names := slices.Clone(p.Composite)
+		for name := range strings.SplitSeq(p.CompositeList, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, fileNamed(name))
+			}
+		}
+		for _, name := range names {
+			switch name {
+			case "tracecontext", "baggage":
+				// Always installed.
+			case "b3", "b3multi":
+				opts = append(opts, WithB3Propagator())
+			case "jaeger":
+				opts = append(opts, WithJaegerPropagator())
+			default:
+				return nil, nil, fmt.Errorf("propagator %q not supported, use tracecontext, baggage, b3, b3multi or jaeger", name)
+			}
+		}
+	}
+
+	var tracerHolders, meterHolders, loggerHolders []*fileExporterHolder
+	if tp := fc.TracerProvider; tp != nil {
+		if tp.Sampler != nil {
+			if ok, err := tp.Sampler.alwaysOn(); err != nil || !ok {
+				return nil, nil, errors.Join(errors.New("sampler: only always_on and parent_based with an always_on root are supported"), err)
+			}
+		}
+		tracerHolders = batchHolders(tp.Processors)
+	}
+	if mp := fc.MeterProvider; mp != nil {
+		for _, r := range mp.Readers {
+			meterHolders = append(meterHolders, r.Periodic)
+		}
+	}
+	if lp := fc.LoggerProvider; lp != nil {
+		loggerHolders = batchHolders(lp.Processors)
+	}
+
+	overrides := map[string]exporterOptions{}
+	for _, s := range []struct {
+		signal  string
+		present bool
+		holders []*fileExporterHolder
+	}{
+		{signalTraces, fc.TracerProvider != nil, tracerHolders},
+		{signalMetrics, fc.MeterProvider != nil, meterHolders},
+		{signalLogs, fc.LoggerProvider != nil, loggerHolders},
+	} {
+		name := "OTEL_" + strings.ToUpper(s.signal) + "_EXPORTER"
+		if !s.present {
+			env[name] = exporterNone
+			continue
+		}
+		if len(s.holders) != 1 || s.holders[0] == nil {
+			return nil, nil, fmt.Errorf("%s: exactly one batch processor or periodic reader is supported", s.signal)
+		}
+		switch exp := s.holders[0].Exporter; {
+		case isSet(&exp.OTLPHTTP) && !isSet(&exp.Console):
+			env[name] = exporterOTLP
+			var otlp fileOTLPHTTP
+			if err := decodeStrict(&exp.OTLPHTTP, &otlp); err != nil {
+				return nil, nil, fmt.Errorf("%s: otlp_http: %w", s.signal, err)
+			}
+			o, err := otlp.exporterOptions()
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.signal, err)
+			}
+			overrides[s.signal] = o
+		case isSet(&exp.Console) && !isSet(&exp.OTLPHTTP):
+			if !isEmpty(&exp.Console) {
+				return nil, nil, fmt.Errorf("%s: console exporter takes no settings", s.signal)
+			}
+			env[name] = exporterConsole
+		default:
+			return nil, nil, fmt.Errorf("%s: exporter must be exactly one of otlp_http or console", s.signal)
+		}
+	}
+	opts = append(opts, func(c *config) {
+		c.exporterOverrides = overrides
+	})
+	return opts, env, nil
+}
+
+// batchHolders returns the batch settings of each processor, nil for
+// processors of any other kind.
+func batchHolders(processors []fileProcessor) []*fileExporterHolder {
+	holders := make([]*fileExporterHolder, len(processors))
+	for i, p := range processors {
+		holders[i] = p.Batch
+	}
+	return holders
+}
+
+// alwaysOn reports whether s samples every trace. A parent_based sampler
+// without root defaults to always_on.
+func (s *fileSampler) alwaysOn() (bool, error) {
+	switch {
+	case isSet(&s.AlwaysOn) && !isSet(&s.ParentBased):
+		return isEmpty(&s.AlwaysOn), nil
+	case isSet(&s.ParentBased) && !isSet(&s.AlwaysOn):
+		var pb struct {
+			Root *fileSampler `yaml:"root"`
+		}
+		if err := decodeStrict(&s.ParentBased, &pb); err != nil {
+			return false, fmt.Errorf("parent_based: %w", err)
+		}
+		if pb.Root == nil {
+			return true, nil
+		}
+		return pb.Root.alwaysOn()
+	default:
+		return false, nil
+	}
+}
+
+// isSet reports whether the key holding n was present in the file.
+func isSet(n *yaml.Node) bool {
+	return n.Kind != 0
+}
+
+// isEmpty reports whether n is a null value or an empty map.
+func isEmpty(n *yaml.Node) bool {
+	return n.Tag == "!!null" || (n.Kind == yaml.MappingNode && len(n.Content) == 0)
+}
+
+// decodeStrict decodes n into out, rejecting unknown fields like the
+// top-level decoder. yaml.Node.Decode does not support that, so n is
+// re-encoded first.
+func decodeStrict(n *yaml.Node, out any) error {
+	data, err := yaml.Marshal(n)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// exporterOptions converts the otlp_http exporter settings. The endpoint is
+// the full URL including the signal path, as in the configuration schema.
+func (e *fileOTLPHTTP) exporterOptions() (exporterOptions, error) {
+	var o exporterOptions
+	if e.Endpoint != "" {
+		u, err := url.Parse(e.Endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return exporterOptions{}, fmt.Errorf("otlp_http: invalid endpoint %q", e.Endpoint)
+		}
+		o.endpointURL, o.urlPath = e.Endpoint, cmp.Or(u.Path, "/")
+	}
+
+	o.headers = map[string]string{}
+	for pair := range strings.SplitSeq(e.HeadersList, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return exporterOptions{}, fmt.Errorf("otlp_http: headers_list entry %q is not name=value", pair)
+		}
+		o.headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	// headers takes precedence over headers_list, as in the schema.
+	for _, h := range e.Headers {
+		o.headers[h.Name] = h.Value
+	}
+	return o, nil
+}
+
+// fileResourceAttributes converts resource attributes, parsing values of
+// the scalar types. Array types are not supported.
+func fileResourceAttributes(items []fileAttributeItem) ([]attribute.KeyValue, error) {
+	attrs := make([]attribute.KeyValue, 0, len(items))
+	for _, a := range items {
+		if a.Name == "" {
+			return nil, errors.New("resource attribute without name")
+		}
+		key := attribute.Key(a.Name)
+		switch a.Type {
+		case "", "string":
+			attrs = append(attrs, key.String(a.Value))
+		case "bool":
+			b, err := strconv.ParseBool(a.Value)
+			if err != nil {
+				return nil, fmt.Errorf("resource attribute %s: %w", a.Name, err)
+			}
+			attrs = append(attrs, key.Bool(b))
+		case "int":
+			n, err := strconv.ParseInt(a.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("resource attribute %s: %w", a.Name, err)
+			}
+			attrs = append(attrs, key.Int64(n))
+		case "double":
+			f, err := strconv.ParseFloat(a.Value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("resource attribute %s: %w", a.Name, err)
+			}
+			attrs = append(attrs, key.Float64(f))
+		default:
+			return nil, fmt.Errorf("resource attribute %s: type %q not supported, use string, bool, int or double", a.Name, a.Type)
+		}
+	}
+	return attrs, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetupFromConfigFileErrorsReturnCleanup(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := map[string]string{
+		"missing file":        filepath.Join(dir, "missing.yaml"),
+		"invalid YAML":        write("invalid.yaml", "file_format: [0.3\n"),
+		"unknown field":       write("unknown.yaml", "file_format: \"0.3\"\nunknown: true\n"),
+		"missing file_format": write("noformat.yaml", "disabled: false\n"),
+		"unsupported exporter": write("exporter.yaml", `file_format: "0.3"
+tracer_provider:
+  processors:
+    - batch:
+        exporter:
+          zipkin: {}
+`),
+	}
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			cleanup, err := SetupFromConfigFile(path)
+			if err == nil {
+				t.Fatal("SetupFromConfigFile returned no error")
+			}
+			if cleanup == nil {
+				t.Fatal("SetupFromConfigFile returned a nil cleanup")
+			}
+			cleanup()
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// contextAttributesKey is the context key for request-scoped attributes.
+type contextAttributesKey struct{}
+
+// WithContextAttributes returns a copy of ctx carrying attrs in addition to
+// any attributes already attached to it. Spans started from the returned
+// context and log records emitted with it (e.g. logger.InfoContext) get the
+// attributes automatically. Metrics are not changed because request-scoped
+// values would multiply their cardinality; pass ContextAttributes(ctx) to
+// metric.WithAttributes explicitly where that is intended.
+//
+// The attributes are copied onto every span and log record, so keep the set
+// small on hot paths.
+func WithContextAttributes(ctx context.Context, attrs ...attribute.KeyValue) context.Context {
+	existing := ContextAttributes(ctx)
+	return context.WithValue(ctx, contextAttributesKey{}, append(slices.Clip(existing), attrs...))
+}
+
+// ContextAttributes returns the attributes attached to ctx by
+// WithContextAttributes.
+func ContextAttributes(ctx context.Context) []attribute.KeyValue {
+	attrs, _ := ctx.Value(contextAttributesKey{}).([]attribute.KeyValue)
+	return attrs
+}
+
+// contextLoggerKey is the context key for a logger set by ContextWithLogger.
+type contextLoggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, typically one
+// derived from GetLogger with logger.With for a component or request, so
+// code further down the call chain can log with the same attributes through
+// LoggerFromContext. Log with the ...Context methods and ctx as well, so
+// records stay correlated with the active span.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextLoggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger attached to ctx by ContextWithLogger,
+// or GetLogger() if there is none.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextLoggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return appLogger
+}
+
+// contextAttributesSpanProcessor adds context attributes to spans on start.
+type contextAttributesSpanProcessor struct{}
+
+func (contextAttributesSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := ContextAttributes(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (contextAttributesSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (contextAttributesSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (contextAttributesSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// contextAttributesLogProcessor adds context attributes to log records. It
+// must be registered before the exporting processor.
+type contextAttributesLogProcessor struct{}
+
+func (contextAttributesLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	for _, kv := range ContextAttributes(ctx) {
+		r.AddAttributes(log.KeyValueFromAttribute(kv))
+	}
+	return nil
+}
+
+func (contextAttributesLogProcessor) Shutdown(context.Context) error   { return nil }
+func (contextAttributesLogProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// defaultDetectorTimeout bounds each resource detector when
+// WithDetectorTimeout is not used.
+const defaultDetectorTimeout = 2 * time.Second
+
+// WithDetectorTimeout limits how long each resource detector may take.
+// A detector that has not returned by then is skipped with a warning, so a
+// metadata service that is unreachable cannot stall startup. Defaults to 2
+// seconds; 0 disables the limit.
+func WithDetectorTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.detectorTimeout = d
+	}
+}
+
+// timeoutDetector runs a detector with a deadline and skips it when the
+// deadline passes.
+type timeoutDetector struct {
+	detector resource.Detector
+	timeout  time.Duration
+	logger   *slog.Logger
+}
+
+func (d timeoutDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	type result struct {
+		res *resource.Resource
+		err error
+	}
+	// Buffered so a detector ignoring ctx can still finish after we gave up.
+	done := make(chan result, 1)
+	go func() {
+		res, err := d.detector.Detect(ctx)
+		done <- result{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		d.logger.Warn("resource detector timed out, skipping it",
+			"detector", fmt.Sprintf("%T", d.detector),
+			"timeout", d.timeout)
+		return resource.Empty(), nil
+	}
+}
+
+// withDetectorTimeout wraps each detector in cfg with the configured timeout.
+func withDetectorTimeout(cfg *config) []resource.Detector {
+	if cfg.detectorTimeout <= 0 {
+		return cfg.detectors
+	}
+	detectors := make([]resource.Detector, len(cfg.detectors))
+	for i, d := range cfg.detectors {
+		detectors[i] = timeoutDetector{detector: d, timeout: cfg.detectorTimeout, logger: cfg.logger}
+	}
+	return detectors
+}
+
+// WithNomadDetector adds resource attributes describing the Nomad allocation
+// the process runs in, read from the NOMAD_* environment variables Nomad
+// injects into tasks. It adds nothing when not running under Nomad.
+func WithNomadDetector() Option {
+	return func(c *config) {
+		c.detectors = append(c.detectors, nomadDetector{cfg: c})
+	}
+}
+
+// nomadEnvAttributes maps Nomad task environment variables to resource
+// attribute keys. NOMAD_NODE_ID and NOMAD_NODE_NAME are not set by Nomad
+// itself; set them in the job's env block from ${node.unique.id} and
+// ${node.unique.name} to get node attributes.
+var nomadEnvAttributes = []struct {
+	env string
+	key attribute.Key
+}{
+	{"NOMAD_ALLOC_ID", "nomad.alloc.id"},
+	{"NOMAD_ALLOC_NAME", "nomad.alloc.name"},
+	{"NOMAD_ALLOC_INDEX", "nomad.alloc.index"},
+	{"NOMAD_JOB_ID", "nomad.job.id"},
+	{"NOMAD_JOB_NAME", "nomad.job.name"},
+	{"NOMAD_GROUP_NAME", "nomad.group.name"},
+	{"NOMAD_TASK_NAME", "nomad.task.name"},
+	{"NOMAD_NAMESPACE", "nomad.namespace"},
+	{"NOMAD_DC", "nomad.datacenter"},
+	{"NOMAD_REGION", "nomad.region"},
+	{"NOMAD_NODE_ID", "nomad.node.id"},
+	{"NOMAD_NODE_NAME", "nomad.node.name"},
+}
+
+// nomadDetector detects the Nomad allocation from the task environment, read
+// through cfg.getenv when Detect runs: options are applied before
+// resolveConfig installs getenv, so it cannot be captured by the option.
+type nomadDetector struct {
+	cfg *config
+}
+
+func (d nomadDetector) Detect(context.Context) (*resource.Resource, error) {
+	if d.cfg.getenv("NOMAD_ALLOC_ID") == "" {
+		return resource.Empty(), nil
+	}
+	var attrs []attribute.KeyValue
+	for _, a := range nomadEnvAttributes {
+		if v := d.cfg.getenv(a.env); v != "" {
+			attrs = append(attrs, a.key.String(v))
+		}
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestNomadDetectorReadsConfigEnv(t *testing.T) {
+	env := map[string]string{
+		"NOMAD_ALLOC_ID":   "5456bd7a-9fc0-c0dd-6131-cbee77f57577",
+		"NOMAD_JOB_NAME":   "checkout",
+		"NOMAD_TASK_NAME":  "api",
+		"NOMAD_NAMESPACE":  "default",
+		"NOMAD_DC":         "dc1",
+		"NOMAD_NODE_NAME":  "",
+		"NOMAD_ALLOC_NAME": "checkout.api[0]",
+	}
+	cfg := testConfig(t, env, WithNomadDetector())
+
+	res, err := cfg.detectors[0].Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := res.Set()
+	for key, want := range map[attribute.Key]string{
+		"nomad.alloc.id":   "5456bd7a-9fc0-c0dd-6131-cbee77f57577",
+		"nomad.alloc.name": "checkout.api[0]",
+		"nomad.job.name":   "checkout",
+		"nomad.task.name":  "api",
+		"nomad.namespace":  "default",
+		"nomad.datacenter": "dc1",
+	} {
+		if got, _ := set.Value(key); got.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+		}
+	}
+	if set.HasValue("nomad.node.name") {
+		t.Error("empty NOMAD_NODE_NAME produced an attribute")
+	}
+}
+
+func TestNomadDetectorOutsideNomad(t *testing.T) {
+	cfg := testConfig(t, nil, WithNomadDetector())
+	res, err := cfg.detectors[0].Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Len() != 0 {
+		t.Errorf("got attributes %v outside Nomad", res.Attributes())
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingEnabled and metricsEnabled report whether setup installed an SDK
+// provider for the signal. They are cleared again by the cleanup function.
+var tracingEnabled, metricsEnabled atomic.Bool
+
+// IsRecording reports whether the span active in ctx records, i.e. whether
+// attributes and events set on it are kept. Use it to skip computing costly
+// attributes that would be discarded:
+//
+//	if IsRecording(ctx) {
+//	    span.SetAttributes(attribute.String("cart.summary", summarize(cart)))
+//	}
+//
+// It is false without an active span, for spans the sampler dropped, for
+// ended spans and whenever tracing is disabled, as no-op spans never record.
+// Spans started through GetTracer before setup record until they end, since
+// they are buffered for replay.
+func IsRecording(ctx context.Context) bool {
+	return trace.SpanFromContext(ctx).IsRecording()
+}
+
+// TracingEnabled reports whether traces are exported. It is false before
+// setup, after the cleanup function ran, and when tracing is disabled with
+// OTEL_TRACES_EXPORTER=none or failed to initialize; GetTracer then hands
+// out spans that record nothing. It does not reflect sampling, use
+// IsRecording for a specific span.
+func TracingEnabled() bool {
+	return tracingEnabled.Load()
+}
+
+// MetricsEnabled reports whether metrics are exported. It is false in the
+// same cases as TracingEnabled, with OTEL_METRICS_EXPORTER=none for the
+// environment variable; instruments from GetMeter then discard all
+// measurements, so callers can skip computing the values and attributes.
+func MetricsEnabled() bool {
+	return metricsEnabled.Load()
+}
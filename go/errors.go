@@ -0,0 +1,43 @@
+package main
+
+import "strings"
+
+// SetupError is returned by Setup when the exporter of one or more signals
+// could not be initialized. Those signals use no-op providers; the others
+// work normally, so callers may log the error and carry on.
+type SetupError struct {
+	// Traces, Metrics and Logs hold the error for each failed signal, or nil.
+	Traces  error
+	Metrics error
+	Logs    error
+}
+
+func (e *SetupError) Error() string {
+	var parts []string
+	for _, s := range []struct {
+		signal string
+		err    error
+	}{{signalTraces, e.Traces}, {signalMetrics, e.Metrics}, {signalLogs, e.Logs}} {
+		if s.err != nil {
+			parts = append(parts, s.signal+": "+s.err.Error())
+		}
+	}
+	return "OpenTelemetry setup incomplete, disabled " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns the errors of the failed signals, for errors.Is and
+// errors.As.
+func (e *SetupError) Unwrap() []error {
+	var errs []error
+	for _, err := range []error{e.Traces, e.Metrics, e.Logs} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// failed reports whether any signal failed.
+func (e *SetupError) failed() bool {
+	return e.Traces != nil || e.Metrics != nil || e.Logs != nil
+}
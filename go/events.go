@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/noop"
+)
+
+// EventNameKey is the attribute carrying an event's name.
+const EventNameKey = attribute.Key("event.name")
+
+// appEventLogger emits the records of EmitEvent. It is set by setup when
+// logging is enabled.
+var appEventLogger log.Logger = noop.NewLoggerProvider().Logger("")
+
+// EmitEvent emits a named event: a log record at Info severity, without a
+// body, whose event name (and event.name attribute, for backends that do
+// not read the OTLP field yet) is name and whose attributes are attrs.
+// Like regular logs it is correlated with the span active in ctx, picks up
+// context attributes and is exported with the other records of GetLogger's
+// scope.
+//
+// A regular log is a message meant for people, with attributes as context. An
+// event is a structured record of something that happened, identified by
+// its name so that backends can count, route and parse events of a kind
+// reliably; put variable data in attrs, not in the name. Events are
+// written straight to the logger provider rather than through slog, so
+// they are not subject to the runtime log level or to the options that
+// wrap GetLogger's handler, and events emitted before setup are dropped.
+func EmitEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	now := time.Now()
+	var r log.Record
+	r.SetEventName(name)
+	r.SetTimestamp(now)
+	r.SetObservedTimestamp(now)
+	r.SetSeverity(log.SeverityInfo)
+	r.SetSeverityText("INFO")
+	r.AddAttributes(log.KeyValueFromAttribute(EventNameKey.String(name)))
+	for _, kv := range attrs {
+		r.AddAttributes(log.KeyValueFromAttribute(kv))
+	}
+	appEventLogger.Emit(ctx, r)
+}
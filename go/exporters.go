@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporter selections accepted in OTEL_TRACES_EXPORTER,
+// OTEL_METRICS_EXPORTER and OTEL_LOGS_EXPORTER.
+const (
+	exporterOTLP    = "otlp"
+	exporterConsole = "console"
+	exporterNone    = "none"
+)
+
+// exporterSelection returns the exporter chosen for signal by its
+// OTEL_<SIGNAL>_EXPORTER environment variable, defaulting to otlp. A signal
+// set to none is not set up at all, leaving the global no-op provider in
+// place; console writes to stdout instead of exporting.
+func exporterSelection(cfg *config, signal string) (string, error) {
+	name := "OTEL_" + strings.ToUpper(signal) + "_EXPORTER"
+	switch v := strings.ToLower(strings.TrimSpace(cfg.getenv(name))); v {
+	case "":
+		return exporterOTLP, nil
+	case exporterNone:
+		cfg.logger.Info("signal disabled", "signal", signal, "env", name+"="+v)
+		return v, nil
+	case exporterOTLP, exporterConsole:
+		return v, nil
+	default:
+		return "", fmt.Errorf("unsupported %s %q, use otlp, console or none", name, v)
+	}
+}
+
+// otlpSignal translates exporterOptions into the options of a signal's
+// OTLP/HTTP exporter package, so the exporters of all signals are created
+// the same way by newOTLPExporter.
+type otlpSignal[E interface{ Shutdown(context.Context) error }, O any] struct {
+	endpointURL func(string) O
+	urlPath     func(string) O
+	headers     func(map[string]string) O
+	client      func(*http.Client) O
+	// noCompression disables the exporter's compression, for when the
+	// client compresses at the configured level.
+	noCompression O
+	create        func(context.Context, ...O) (E, error)
+	route         func(route func(RoutedRecord) string, def E, create func(string) (E, error)) E
+}
+
+var (
+	otlpTraces = otlpSignal[sdktrace.SpanExporter, otlptracehttp.Option]{
+		endpointURL:   otlptracehttp.WithEndpointURL,
+		urlPath:       otlptracehttp.WithURLPath,
+		headers:       otlptracehttp.WithHeaders,
+		client:        otlptracehttp.WithHTTPClient,
+		noCompression: otlptracehttp.WithCompression(otlptracehttp.NoCompression),
+		create: func(ctx context.Context, opts ...otlptracehttp.Option) (sdktrace.SpanExporter, error) {
+			return otlptracehttp.New(ctx, opts...)
+		},
+		route: func(route func(RoutedRecord) string, def sdktrace.SpanExporter, create func(string) (sdktrace.SpanExporter, error)) sdktrace.SpanExporter {
+			return newRoutingSpanExporter(route, def, create)
+		},
+	}
+	otlpMetrics = otlpSignal[sdkmetric.Exporter, otlpmetrichttp.Option]{
+		endpointURL:   otlpmetrichttp.WithEndpointURL,
+		urlPath:       otlpmetrichttp.WithURLPath,
+		headers:       otlpmetrichttp.WithHeaders,
+		client:        otlpmetrichttp.WithHTTPClient,
+		noCompression: otlpmetrichttp.WithCompression(otlpmetrichttp.NoCompression),
+		create: func(ctx context.Context, opts ...otlpmetrichttp.Option) (sdkmetric.Exporter, error) {
+			return otlpmetrichttp.New(ctx, opts...)
+		},
+		route: func(route func(RoutedRecord) string, def sdkmetric.Exporter, create func(string) (sdkmetric.Exporter, error)) sdkmetric.Exporter {
+			return newRoutingMetricExporter(route, def, create)
+		},
+	}
+	otlpLogs = otlpSignal[sdklog.Exporter, otlploghttp.Option]{
+		endpointURL:   otlploghttp.WithEndpointURL,
+		urlPath:       otlploghttp.WithURLPath,
+		headers:       otlploghttp.WithHeaders,
+		client:        otlploghttp.WithHTTPClient,
+		noCompression: otlploghttp.WithCompression(otlploghttp.NoCompression),
+		create: func(ctx context.Context, opts ...otlploghttp.Option) (sdklog.Exporter, error) {
+			return otlploghttp.New(ctx, opts...)
+		},
+		route: func(route func(RoutedRecord) string, def sdklog.Exporter, create func(string) (sdklog.Exporter, error)) sdklog.Exporter {
+			return newRoutingLogExporter(route, def, create)
+		},
+	}
+)
+
+// newOTLPExporter creates the OTLP exporter of signal, with extra options
+// after the shared ones. With WithSetupRetry it probes the collector,
+// shutting the exporter down again if the probe fails, and with
+// WithTargetPackageRouter it routes to exporters created on first use.
+func newOTLPExporter[E interface{ Shutdown(context.Context) error }, O any](ctx context.Context, cfg *config, s otlpSignal[E, O], signal, otlpEndpoint, bearerToken string, extra ...O) (E, error) {
+	exp := buildExporterOptions(cfg, signal, otlpEndpoint, bearerToken)
+	opts := []O{
+		s.endpointURL(exp.endpointURL),
+		s.urlPath(exp.urlPath),
+		s.headers(exp.headers),
+		s.client(exp.client),
+	}
+	if cfg.compression {
+		opts = append(opts, s.noCompression)
+	}
+	opts = append(opts, extra...)
+
+	e, err := s.create(ctx, opts...)
+	if err != nil {
+		return e, err
+	}
+	if cfg.setupAttempts > 1 {
+		if err := probeCollector(ctx, exp); err != nil {
+			// A retry creates a new exporter
+			_ = e.Shutdown(context.WithoutCancel(ctx))
+			var zero E
+			return zero, err
+		}
+	}
+	if cfg.targetPackageRouter != nil {
+		// Routed exporters are created during exports, after setup returned
+		create := func(targetPackage string) (E, error) {
+			return s.create(context.WithoutCancel(ctx), append(slices.Clip(opts), s.headers(withTargetPackageHeader(exp.headers, targetPackage)))...)
+		}
+		e = s.route(cfg.targetPackageRouter, e, create)
+	}
+	return e, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewOTLPExporterRoutesTargetPackages(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		packages []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mu.Lock()
+		packages = append(packages, r.Header.Get("x-observe-target-package"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t, map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL},
+		WithCompressionLevel(1),
+		WithTargetPackageRouter(func(r RoutedRecord) string {
+			if r.Name == "charge" {
+				return "Billing"
+			}
+			return ""
+		}),
+	)
+	exporter, err := newOTLPExporter(context.Background(), cfg, otlpTraces, signalTraces, cfg.otlpEndpoint, cfg.bearerToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Shutdown(context.Background())
+
+	spans := tracetest.SpanStubs{{Name: "GET /checkout"}, {Name: "charge"}}.Snapshots()
+	if err := exporter.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(packages)
+	if want := []string{"Billing", "Tracing"}; !slices.Equal(packages, want) {
+		t.Errorf("target packages = %v, want %v", packages, want)
+	}
+}
+
+func TestNewOTLPExporterFailedProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(t, map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL}, WithSetupRetry(2, 0))
+	if _, err := newOTLPExporter(context.Background(), cfg, otlpLogs, signalLogs, cfg.otlpEndpoint, cfg.bearerToken); err == nil {
+		t.Fatal("exporter created although the collector probe failed")
+	}
+}
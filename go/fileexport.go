@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// exportFile holds the settings of a WithFileExporter file.
+type exportFile struct {
+	path     string
+	maxBytes int64
+	backups  int
+	network  bool
+}
+
+// FileExporterOption customizes a file written by WithFileExporter.
+type FileExporterOption func(*exportFile)
+
+// WithFileExporter writes the OTLP export requests of signal ("traces",
+// "metrics" or "logs") to the file at path instead of sending them to the
+// collector, for air-gapped environments or to keep a copy for later
+// analysis. The file is appended to and created if needed.
+//
+// The file is a sequence of length-delimited records: each is the length of
+// an OTLP/protobuf export request (ExportTraceServiceRequest,
+// ExportMetricsServiceRequest or ExportLogsServiceRequest) encoded as a
+// protobuf base-128 varint, followed by the uncompressed request itself,
+// the delimited format read by e.g. Java's parseDelimitedFrom. To replay a
+// file, POST each request to the collector's /v1/<signal> endpoint with
+// Content-Type application/x-protobuf.
+//
+// Each signal needs its own file, and the signal must use the OTLP exporter,
+// the default; the file takes the place of the network, so endpoint
+// settings do not matter unless WithFileAlongsideNetwork is given. Errors
+// writing the file are reported like failed exports.
+func WithFileExporter(signal, path string, opts ...FileExporterOption) Option {
+	return func(c *config) {
+		f := &exportFile{path: path}
+		for _, opt := range opts {
+			opt(f)
+		}
+		if c.exportFiles == nil {
+			c.exportFiles = map[string]*exportFile{}
+		}
+		c.exportFiles[signal] = f
+	}
+}
+
+// WithFileRotation starts a new file once writing a request would grow the
+// current one beyond maxBytes. The full file is renamed to path.1, older
+// ones are shifted to path.2 and so on, and those beyond backups are
+// deleted. A request larger than maxBytes is written to a file of its own.
+func WithFileRotation(maxBytes int64, backups int) FileExporterOption {
+	return func(f *exportFile) {
+		f.maxBytes = maxBytes
+		f.backups = backups
+	}
+}
+
+// WithFileAlongsideNetwork sends the requests to the collector as well, so
+// the file is a copy of what was exported. Requests are written to the file
+// whether or not the collector accepts them.
+func WithFileAlongsideNetwork() FileExporterOption {
+	return func(f *exportFile) {
+		f.network = true
+	}
+}
+
+// checkExportFiles reports an error for unknown signals and for files
+// shared by several signals, whose requests could not be told apart.
+func checkExportFiles(files map[string]*exportFile) error {
+	signals := map[string]string{}
+	for signal, f := range files {
+		if _, ok := targetPackages[signal]; !ok {
+			return fmt.Errorf("file exporter for unknown signal %q, use traces, metrics or logs", signal)
+		}
+		if other, ok := signals[f.path]; ok {
+			return fmt.Errorf("file exporter path %q used by both %s and %s", f.path, other, signal)
+		}
+		signals[f.path] = signal
+	}
+	return nil
+}
+
+// fileTransport writes every export request to a file. Without a base it
+// answers the requests itself as an accepting collector would.
+type fileTransport struct {
+	base http.RoundTripper
+	cfg  *exportFile
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func (t *fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(probeContextKey{}) == nil {
+		body, r, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = r
+		if body, err = decodeBody(body, req.Header.Get("Content-Encoding")); err != nil {
+			return nil, err
+		}
+		if err := t.write(body); err != nil {
+			return nil, fmt.Errorf("file exporter: %w", err)
+		}
+	}
+	if t.base != nil {
+		return t.base.RoundTrip(req)
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/x-protobuf"}},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// write appends msg as a length-delimited record, rotating the file first
+// if it would grow beyond the limit.
+func (t *fileTransport) write(msg []byte) error {
+	record := binary.AppendUvarint(nil, uint64(len(msg)))
+	record = append(record, msg...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			return err
+		}
+	}
+	if t.cfg.maxBytes > 0 && t.size > 0 && t.size+int64(len(record)) > t.cfg.maxBytes {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := t.file.Write(record)
+	t.size += int64(n)
+	return err
+}
+
+// open opens the file for appending. t.mu must be held.
+func (t *fileTransport) open() error {
+	f, err := os.OpenFile(t.cfg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	t.file, t.size = f, info.Size()
+	return nil
+}
+
+// rotate moves the current file to path.1, shifting and pruning older
+// backups, and opens a new one. t.mu must be held.
+func (t *fileTransport) rotate() error {
+	if err := t.file.Close(); err != nil {
+		return err
+	}
+	t.file = nil
+	path := t.cfg.path
+	if t.cfg.backups <= 0 {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return t.open()
+	}
+	if err := os.Remove(path + "." + strconv.Itoa(t.cfg.backups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := t.cfg.backups - 1; i >= 1; i-- {
+		err := os.Rename(path+"."+strconv.Itoa(i), path+"."+strconv.Itoa(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return err
+	}
+	return t.open()
+}
+
+// decodeBody returns the uncompressed export request body.
+func decodeBody(body []byte, encoding string) ([]byte, error) {
+	if encoding != "gzip" {
+		return body, nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// otelFlags holds the values of the flags registered by RegisterFlags.
+var otelFlags struct {
+	endpoint    string
+	token       string
+	serviceName string
+	attrs       resourceAttrFlag
+}
+
+// RegisterFlags registers the telemetry flags on fs, for use with
+// SetupFromFlags:
+//
+//	--otel-endpoint        OTLP endpoint (OTEL_EXPORTER_OTLP_ENDPOINT)
+//	--otel-token           bearer token (OTEL_EXPORTER_OTLP_BEARER_TOKEN)
+//	--service-name         service name (OTEL_SERVICE_NAME)
+//	--otel-resource-attr   key=value resource attribute, repeatable
+//
+// A flag that is set takes precedence over its environment variable; unset
+// flags fall back to the environment. Call it before fs.Parse.
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&otelFlags.endpoint, "otel-endpoint", "", "OTLP endpoint; overrides OTEL_EXPORTER_OTLP_ENDPOINT")
+	fs.StringVar(&otelFlags.token, "otel-token", "", "OTLP bearer token; overrides OTEL_EXPORTER_OTLP_BEARER_TOKEN")
+	fs.StringVar(&otelFlags.serviceName, "service-name", "", "service name; overrides OTEL_SERVICE_NAME")
+	fs.Var(&otelFlags.attrs, "otel-resource-attr", "resource attribute as key=value; repeatable, overrides OTEL_RESOURCE_ATTRIBUTES")
+}
+
+// SetupFromFlags is Setup configured from the flags registered by
+// RegisterFlags, falling back to the environment for flags that were not set.
+// The service name comes from --service-name, then OTEL_SERVICE_NAME, then
+// the program name. Call it after the flag set has been parsed.
+func SetupFromFlags(opts ...Option) (func(), error) {
+	serviceName := otelFlags.serviceName
+	if serviceName == "" {
+		serviceName = os.Getenv("OTEL_SERVICE_NAME")
+	}
+	if serviceName == "" {
+		serviceName = filepath.Base(os.Args[0])
+	}
+
+	getenv := func(key string) string {
+		switch {
+		case key == "OTEL_EXPORTER_OTLP_ENDPOINT" && otelFlags.endpoint != "":
+			return otelFlags.endpoint
+		case key == "OTEL_EXPORTER_OTLP_BEARER_TOKEN" && otelFlags.token != "":
+			return otelFlags.token
+		}
+		return os.Getenv(key)
+	}
+	opts = append(slices.Clip(opts), func(c *config) {
+		c.resourceAttributes = append(c.resourceAttributes, otelFlags.attrs...)
+	})
+	return setup(context.Background(), serviceName, getenv, opts...)
+}
+
+// resourceAttrFlag collects repeated key=value flags as attributes.
+type resourceAttrFlag []attribute.KeyValue
+
+func (f *resourceAttrFlag) String() string {
+	pairs := make([]string, len(*f))
+	for i, kv := range *f {
+		pairs[i] = string(kv.Key) + "=" + kv.Value.Emit()
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f *resourceAttrFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return errors.New("expected key=value")
+	}
+	*f = append(*f, attribute.String(key, value))
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// flusher is implemented by the SDK providers.
+type flusher interface {
+	ForceFlush(context.Context) error
+}
+
+// ForceFlush exports the telemetry buffered by the global tracer, meter and
+// logger providers, e.g. before a short-lived job exits or when a request
+// must not leave telemetry behind. It honors ctx: once ctx is canceled or
+// its deadline passes, ForceFlush returns promptly with ctx.Err() instead of
+// waiting for the exporters' own 10 second timeout, and the remaining
+// providers are not flushed. Exports interrupted that way may lose their
+// batch. With WithManualMetricReader, metrics are collected and exported as
+// by CollectAndExport.
+func ForceFlush(ctx context.Context) error {
+	steps := []func() error{
+		func() error { return flush(ctx, otel.GetTracerProvider()) },
+		func() error {
+			if manualReader != nil {
+				return CollectAndExport(ctx)
+			}
+			return flush(ctx, otel.GetMeterProvider())
+		},
+		func() error { return flush(ctx, global.GetLoggerProvider()) },
+	}
+	var errs []error
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			break
+		}
+		// Errors caused by ctx are reported once, below.
+		if err := step(); err != nil && ctx.Err() == nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(append(errs, ctx.Err())...)
+}
+
+// flush flushes provider if it buffers telemetry; the no-op providers
+// installed before setup do not.
+func flush(ctx context.Context, provider any) error {
+	if f, ok := provider.(flusher); ok {
+		return f.ForceFlush(ctx)
+	}
+	return nil
+}
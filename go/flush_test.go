@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// blockingSpanExporter blocks each export until its context is done.
+type blockingSpanExporter struct {
+	calls atomic.Int32
+}
+
+func (e *blockingSpanExporter) ExportSpans(ctx context.Context, _ []sdktrace.ReadOnlySpan) error {
+	e.calls.Add(1)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (e *blockingSpanExporter) Shutdown(context.Context) error { return nil }
+
+// installBlockingTracer makes a tracer provider exporting through a
+// blockingSpanExporter global for the test, with one span buffered.
+func installBlockingTracer(t *testing.T) *blockingSpanExporter {
+	t.Helper()
+	exp := &blockingSpanExporter{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp, sdktrace.WithBatchTimeout(time.Hour)))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_ = tp.Shutdown(ctx)
+	})
+	_, span := tp.Tracer("test").Start(context.Background(), "buffered")
+	span.End()
+	return exp
+}
+
+func TestForceFlushCanceledContext(t *testing.T) {
+	exp := installBlockingTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := ForceFlush(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ForceFlush took %v with a canceled context", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ForceFlush = %v, want context.Canceled", err)
+	}
+	if n := exp.calls.Load(); n != 0 {
+		t.Errorf("exporter called %d times after cancellation", n)
+	}
+}
+
+func TestForceFlushDeadline(t *testing.T) {
+	installBlockingTracer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := ForceFlush(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ForceFlush took %v past a 50ms deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ForceFlush = %v, want context.DeadlineExceeded", err)
+	}
+}
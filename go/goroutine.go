@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GoroutineIDKey is the span attribute set by WithGoroutineID.
+const GoroutineIDKey = attribute.Key("goroutine.id")
+
+// WithGoroutineID records the ID of the goroutine that started each span as
+// goroutine.id, to untangle interleaved concurrent work. Go does not expose
+// goroutine IDs, so the ID is parsed from the runtime's stack trace header,
+// which costs roughly a microsecond per span; keep it for debugging. IDs are
+// unique among live goroutines but are reused after a goroutine exits, and
+// spans started before setup get the ID of the goroutine that ran setup.
+func WithGoroutineID() Option {
+	return func(c *config) {
+		c.goroutineID = true
+	}
+}
+
+// goroutineIDSpanProcessor sets goroutine.id on spans. OnStart runs on the
+// goroutine calling Tracer.Start.
+type goroutineIDSpanProcessor struct{}
+
+func (goroutineIDSpanProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if id, ok := goroutineID(); ok {
+		s.SetAttributes(GoroutineIDKey.Int64(id))
+	}
+}
+
+func (goroutineIDSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (goroutineIDSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (goroutineIDSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// goroutineID returns the current goroutine's ID from the first line of its
+// stack trace, "goroutine 18 [running]:".
+func goroutineID() (int64, bool) {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b, ok := bytes.CutPrefix(b, []byte("goroutine "))
+	if !ok {
+		return 0, false
+	}
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	return id, err == nil
+}
+
+// Go runs fn in a new goroutine inside a span called name that is a child
+// of the span in ctx, so background work stays part of the caller's trace.
+// fn receives the span's context. A panic in fn is recovered, recorded on the
+// span as an exception with its stack trace and logged at Error level; it
+// does not crash the process.
+//
+// ctx is passed on as is, so fn is canceled with it. For work that must
+// outlive a request, pass context.WithoutCancel(ctx).
+func Go(ctx context.Context, name string, fn func(context.Context)) {
+	ctx, span := appTracer.Start(ctx, name)
+	go func() {
+		defer span.End()
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("panic: %v", r)
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, err.Error())
+				appLogger.ErrorContext(ctx, "recovered panic in goroutine", "goroutine", name, "error", err)
+			}
+		}()
+		fn(ctx)
+	}()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WithHeartbeat reports service.heartbeat, a gauge that is always 1, on
+// every metric collection. A healthy but idle service keeps sending it, so a
+// gap in the series means the process stopped. interval sets how often
+// metrics are exported, for all instruments, like OTEL_METRIC_EXPORT_INTERVAL
+// which it overrides; 0 keeps that setting (60s by default). Alerts on a
+// missing heartbeat should allow a few intervals. With
+// WithManualMetricReader the heartbeat is reported on each CollectAndExport
+// and interval is ignored.
+//
+// The gauge has no goroutine of its own: it is observed by the meter
+// provider's reader and stops with it when the cleanup function runs.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *config) {
+		c.heartbeat = true
+		c.heartbeatInterval = interval
+	}
+}
+
+// registerHeartbeat registers the WithHeartbeat gauge on the global meter.
+func registerHeartbeat() error {
+	_, err := otel.Meter(selfScopeName).Int64ObservableGauge("service.heartbeat",
+		metric.WithDescription("Always 1 while the process is running."),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(1)
+			return nil
+		}),
+	)
+	return err
+}
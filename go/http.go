@@ -0,0 +1,236 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// httpConfig holds the settings that can be customized through HTTPOptions.
+type httpConfig struct {
+	bodySizes  bool
+	routes     map[string]sdktrace.SamplingDecision
+	redactKeys map[string]bool
+}
+
+// HTTPOption customizes the HTTP instrumentation created by NewHTTPHandler
+// and NewHTTPTransport.
+type HTTPOption func(*httpConfig)
+
+func newHTTPConfig(opts ...HTTPOption) *httpConfig {
+	cfg := &httpConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithBodySizes records http.request.body.size and http.response.body.size
+// on the server span, and in the http.server.request.body.size and
+// http.server.response.body.size histograms (unit "By") of GetMeter's
+// scope, by method and status code. The request size comes from
+// Content-Length, falling back to counting the bytes read from the body.
+// Opt-in because every request and response is wrapped to count bytes.
+func WithBodySizes() HTTPOption {
+	return func(c *httpConfig) {
+		c.bodySizes = true
+	}
+}
+
+// WithRouteSampling overrides the sampling decision for requests whose path
+// matches a key of routes. Keys match the URL path exactly, or, when they end
+// in "/", any path below them. The longest matching key wins. The decision
+// applies to the server span even if the request carries a sampled parent,
+// and child spans inherit it.
+//
+//	WithRouteSampling(map[string]sdktrace.SamplingDecision{
+//	    "/checkout": sdktrace.RecordAndSample,
+//	    "/metrics":  sdktrace.Drop,
+//	})
+func WithRouteSampling(routes map[string]sdktrace.SamplingDecision) HTTPOption {
+	return func(c *httpConfig) {
+		c.routes = routes
+	}
+}
+
+// NewHTTPHandler wraps h with OpenTelemetry HTTP server instrumentation.
+// operation names the server spans.
+func NewHTTPHandler(h http.Handler, operation string, opts ...HTTPOption) http.Handler {
+	return newHTTPHandler(h, operation, newHTTPConfig(opts...))
+}
+
+// InstrumentDefaultMux returns http.DefaultServeMux wrapped with HTTP server
+// instrumentation, for services that register handlers with http.Handle and
+// http.HandleFunc. Serve the returned handler instead of passing nil:
+//
+//	http.ListenAndServe(":8080", InstrumentDefaultMux())
+//
+// Spans are named after the matched mux pattern (e.g. "GET /users/{id}"),
+// which is also recorded as http.route; requests no pattern matches are named
+// after the method alone. The mux itself is left unchanged, so code serving
+// http.DefaultServeMux directly, or handlers registered on other muxes, are
+// not instrumented.
+func InstrumentDefaultMux(opts ...HTTPOption) http.Handler {
+	mux := http.DefaultServeMux
+	routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(routeOf(pattern)))
+		}
+		mux.ServeHTTP(w, r)
+	})
+	return newHTTPHandler(routed, "", newHTTPConfig(opts...),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			_, pattern := mux.Handler(r)
+			if route := routeOf(pattern); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}),
+	)
+}
+
+// routeOf strips the method and host from a ServeMux pattern such as
+// "GET example.com/users/{id}", leaving the path.
+func routeOf(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		pattern = path
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// newHTTPHandler wraps h with otelhttp and the instrumentation selected by
+// cfg. otelOpts are passed on to otelhttp.NewHandler.
+func newHTTPHandler(h http.Handler, operation string, cfg *httpConfig, otelOpts ...otelhttp.Option) http.Handler {
+	if cfg.bodySizes {
+		h = bodySizeHandler(h)
+	}
+	if len(cfg.redactKeys) > 0 {
+		h = redactionHandler(otelhttp.NewHandler(restoreHandler(h), operation, otelOpts...), cfg.redactKeys)
+	} else {
+		h = otelhttp.NewHandler(h, operation, otelOpts...)
+	}
+	if len(cfg.routes) > 0 {
+		h = routeSamplingHandler(h, cfg.routes)
+	}
+	return h
+}
+
+// NewHTTPTransport wraps base with OpenTelemetry HTTP client
+// instrumentation. A nil base uses http.DefaultTransport. Of the HTTPOptions
+// only WithURLRedaction applies to clients.
+func NewHTTPTransport(base http.RoundTripper, opts ...HTTPOption) http.RoundTripper {
+	cfg := newHTTPConfig(opts...)
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if len(cfg.redactKeys) > 0 {
+		return redactionTransport{next: otelhttp.NewTransport(restoreTransport{base: base}), keys: cfg.redactKeys}
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// routeSamplingHandler attaches the sampling decision for the request path to
+// the context before the server span is started.
+func routeSamplingHandler(h http.Handler, routes map[string]sdktrace.SamplingDecision) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pattern, decision, ok := matchRoute(routes, r.URL.Path); ok {
+			r = r.WithContext(contextWithSamplingHint(r.Context(), decision, "rule:"+pattern))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// matchRoute returns the longest key in routes matching path and its value.
+func matchRoute[V any](routes map[string]V, path string) (string, V, bool) {
+	var (
+		bestPattern string
+		best        V
+		found       bool
+	)
+	for pattern, v := range routes {
+		if found && len(pattern) <= len(bestPattern) {
+			continue
+		}
+		if pattern == path || (strings.HasSuffix(pattern, "/") && strings.HasPrefix(path, pattern)) {
+			bestPattern, best, found = pattern, v, true
+		}
+	}
+	return bestPattern, best, found
+}
+
+// bodySizeHandler records the request and response body sizes on the span
+// started by the enclosing otelhttp handler and in histograms.
+func bodySizeHandler(h http.Handler) http.Handler {
+	m := meter()
+	requestSizes, err := m.Int64Histogram("http.server.request.body.size",
+		metric.WithDescription("Size of HTTP server request bodies."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	responseSizes, err := m.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body *countingReader
+		if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		sizes := httpsnoop.CaptureMetricsFn(w, func(w http.ResponseWriter) {
+			h.ServeHTTP(w, r)
+		})
+
+		requestSize := r.ContentLength
+		if body != nil {
+			requestSize = body.n
+		}
+		requestSize = max(requestSize, 0)
+		trace.SpanFromContext(r.Context()).SetAttributes(
+			attribute.Int64("http.request.body.size", requestSize),
+			attribute.Int64("http.response.body.size", sizes.Written),
+		)
+		attrs := metric.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPResponseStatusCode(sizes.Code),
+		)
+		if requestSizes != nil {
+			requestSizes.Record(r.Context(), requestSize, attrs)
+		}
+		if responseSizes != nil {
+			responseSizes.Record(r.Context(), sizes.Written, attrs)
+		}
+	})
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
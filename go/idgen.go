@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithIDGenerator replaces the tracer provider's random trace and span ID
+// generator, e.g. with NewSequentialIDGenerator in tests. Production code
+// should keep the default: IDs must be random to be unique across
+// processes and for ratio-based sampling to work.
+func WithIDGenerator(gen sdktrace.IDGenerator) Option {
+	return func(c *config) {
+		c.idGenerator = gen
+	}
+}
+
+// SequentialIDGenerator generates predictable IDs from a counter, so tests
+// can assert on exact trace and span IDs. The n-th ID generated, counting
+// from 1, is n encoded big-endian: the first span of a fresh generator gets
+// trace ID 00000000000000000000000000000001 and span ID 0000000000000001.
+// It is safe for concurrent use, but the IDs then depend on scheduling.
+type SequentialIDGenerator struct {
+	traces atomic.Uint64
+	spans  atomic.Uint64
+}
+
+// NewSequentialIDGenerator returns a generator starting from 1.
+func NewSequentialIDGenerator() *SequentialIDGenerator {
+	return &SequentialIDGenerator{}
+}
+
+func (g *SequentialIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	binary.BigEndian.PutUint64(tid[8:], g.traces.Add(1))
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+func (g *SequentialIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	var sid trace.SpanID
+	binary.BigEndian.PutUint64(sid[:], g.spans.Add(1))
+	return sid
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogRecordCountKey is the attribute WithLogDedup sets on a coalesced record
+// to the number of identical records it stands for.
+const LogRecordCountKey = attribute.Key("log.record.count")
+
+// WithLogDedup coalesces identical consecutive log records, e.g. from a
+// retry loop logging the same error thousands of times: a run of records
+// with the same key within window of its first record is exported as that
+// first record, with LogRecordCountKey set to the length of the run. key
+// decides which records are identical; nil compares severity, body and
+// attributes.
+//
+// Only consecutive records are coalesced: any other record in between
+// ends the run, so two storms interleaving are not reduced. To see whether
+// the next record repeats it, each record is held back until a different
+// one arrives or window has passed since it was emitted, which delays all
+// logs by up to window. Only the record of the current run is held, so
+// memory use does not grow with the storm. Records within a run are
+// usually not exact duplicates (timestamps and trace context differ), and
+// only the first one's are kept. With WithFlushOnError, a run of errors is
+// flushed when it ends rather than at its first record.
+func WithLogDedup(window time.Duration, key func(*sdklog.Record) string) Option {
+	return func(c *config) {
+		c.logDedupWindow = window
+		c.logDedupKey = key
+	}
+}
+
+// dedupProcessor coalesces runs of identical records before passing them to
+// next, the exporting processor.
+type dedupProcessor struct {
+	next   sdklog.Processor
+	window time.Duration
+	key    func(*sdklog.Record) string
+
+	mu         sync.Mutex
+	pending    *sdklog.Record
+	pendingKey string
+	count      int64
+	timer      *time.Timer
+}
+
+func newDedupProcessor(next sdklog.Processor, window time.Duration, key func(*sdklog.Record) string) *dedupProcessor {
+	if key == nil {
+		key = defaultDedupKey
+	}
+	return &dedupProcessor{next: next, window: window, key: key}
+}
+
+func (p *dedupProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	k := p.key(r)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending != nil && p.pendingKey == k {
+		p.count++
+		return nil
+	}
+	err := p.emitPending(ctx)
+	held := r.Clone()
+	p.pending, p.pendingKey, p.count = &held, k, 1
+	p.timer = time.AfterFunc(p.window, func() { p.expire(&held) })
+	return err
+}
+
+// expire emits r once its window has passed, unless it was already emitted.
+func (p *dedupProcessor) expire(r *sdklog.Record) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == r {
+		_ = p.emitPending(context.Background())
+	}
+}
+
+// emitPending passes the pending record on to next. p.mu must be held.
+func (p *dedupProcessor) emitPending(ctx context.Context) error {
+	if p.pending == nil {
+		return nil
+	}
+	r := p.pending
+	if p.count > 1 {
+		r.AddAttributes(log.Int64(string(LogRecordCountKey), p.count))
+	}
+	p.timer.Stop()
+	p.pending, p.pendingKey, p.count, p.timer = nil, "", 0, nil
+	return p.next.OnEmit(ctx, r)
+}
+
+func (p *dedupProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	err := p.emitPending(ctx)
+	p.mu.Unlock()
+	if sErr := p.next.Shutdown(ctx); sErr != nil {
+		return sErr
+	}
+	return err
+}
+
+func (p *dedupProcessor) ForceFlush(ctx context.Context) error {
+	p.mu.Lock()
+	err := p.emitPending(ctx)
+	p.mu.Unlock()
+	if fErr := p.next.ForceFlush(ctx); fErr != nil {
+		return fErr
+	}
+	return err
+}
+
+// defaultDedupKey identifies a record by its severity, body and attributes.
+func defaultDedupKey(r *sdklog.Record) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(r.Severity())))
+	b.WriteByte(0)
+	b.WriteString(r.Body().String())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		b.WriteByte(0)
+		b.WriteString(kv.Key)
+		b.WriteByte('=')
+		b.WriteString(kv.Value.String())
+		return true
+	})
+	return b.String()
+}
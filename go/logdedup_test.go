@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// flushRecordingProcessor keeps the records emitted to it and signals each
+// ForceFlush on flushed.
+type flushRecordingProcessor struct {
+	recordingLogProcessor
+	flushed chan struct{}
+}
+
+func (p *flushRecordingProcessor) ForceFlush(context.Context) error {
+	p.flushed <- struct{}{}
+	return nil
+}
+
+// emitRecord emits a record with body and severity to p.
+func emitRecord(t *testing.T, p sdklog.Processor, body string, severity log.Severity) {
+	t.Helper()
+	var r sdklog.Record
+	r.SetBody(log.StringValue(body))
+	r.SetSeverity(severity)
+	if err := p.OnEmit(context.Background(), &r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordCount returns the LogRecordCountKey attribute of r, or 1.
+func recordCount(r sdklog.Record) int64 {
+	count := int64(1)
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		if kv.Key == string(LogRecordCountKey) {
+			count = kv.Value.AsInt64()
+		}
+		return true
+	})
+	return count
+}
+
+func TestDedupRunNotEndedByFlushOnError(t *testing.T) {
+	rec := &flushRecordingProcessor{flushed: make(chan struct{}, 10)}
+	p := newDedupProcessor(&flushOnErrorProcessor{Processor: rec}, time.Hour, nil)
+
+	for range 3 {
+		emitRecord(t, p, "connection refused", log.SeverityError)
+	}
+	select {
+	case <-rec.flushed:
+		t.Fatal("flushed while the run of errors is still open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	emitRecord(t, p, "recovered", log.SeverityInfo)
+	select {
+	case <-rec.flushed:
+	case <-time.After(time.Second):
+		t.Fatal("error run not flushed once it ended")
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.records) != 1 || recordCount(rec.records[0]) != 3 {
+		t.Fatalf("got %d records, want the error run coalesced into one record of count 3", len(rec.records))
+	}
+}
+
+func TestDedupRunCrossingExpiry(t *testing.T) {
+	rec := &recordingLogProcessor{}
+	p := newDedupProcessor(rec, 50*time.Millisecond, nil)
+	waitRecords := func(n int) []sdklog.Record {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rec.mu.Lock()
+			got := slices.Clone(rec.records)
+			rec.mu.Unlock()
+			if len(got) >= n || time.Now().After(deadline) {
+				return got
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	emitRecord(t, p, "retrying", log.SeverityWarn)
+	emitRecord(t, p, "retrying", log.SeverityWarn)
+	got := waitRecords(1)
+	if len(got) != 1 || recordCount(got[0]) != 2 {
+		t.Fatalf("got %d records after the window, want the run emitted once with count 2", len(got))
+	}
+
+	// A repeat after the window starts a new run instead of extending the
+	// emitted one.
+	emitRecord(t, p, "retrying", log.SeverityWarn)
+	got = waitRecords(2)
+	if len(got) != 2 {
+		t.Fatalf("got %d records, want a second run after the expiry", len(got))
+	}
+	if recordCount(got[1]) != 1 {
+		t.Errorf("second run has count %d, want a single record", recordCount(got[1]))
+	}
+
+	// Shutdown emits a pending run and stops its timer.
+	emitRecord(t, p, "closing", log.SeverityInfo)
+	emitRecord(t, p, "closing", log.SeverityInfo)
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	got = waitRecords(3)
+	if len(got) != 3 || recordCount(got[2]) != 2 {
+		t.Errorf("got %d records after shutdown, want the pending run emitted once with count 2", len(got))
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// recordOverheadBytes approximates the encoded size of a log record's fixed
+// fields (timestamps, severity, trace and span IDs, field tags).
+const recordOverheadBytes = 64
+
+// truncatedSuffix marks a log body that was cut to fit the payload limit.
+const truncatedSuffix = "...[truncated]"
+
+// WithMaxPayloadBytes caps the approximate size of each log export request.
+// Batches larger than the limit are split into several requests. A record
+// that alone exceeds the limit has its string body truncated to fit; if it is
+// still too large (non-string body or large attributes) it is sent on its own,
+// so if the collector rejects it the rest of the batch still gets through.
+// A value of 0, the default, disables the limit.
+func WithMaxPayloadBytes(n int) Option {
+	return func(c *config) {
+		c.maxPayloadBytes = n
+	}
+}
+
+// WithFlushOnError exports log records at Error severity or above right away
+// instead of waiting for the next batch, so they reach the backend even if
+// the process crashes shortly after. The flush runs in the background. Flushes
+// are never concurrent: errors logged while one is running trigger a single
+// follow-up flush, so bursts of errors do not cause a flush storm. With
+// WithLogDedup, an error is flushed once its run of identical records ends,
+// so flushing does not cut runs short.
+func WithFlushOnError() Option {
+	return func(c *config) {
+		c.flushOnError = true
+	}
+}
+
+// WithLogAttributes adds attrs to every exported log record, such as the
+// owning team or cost center. Unlike resource attributes they are stored on
+// the record itself, for backends that index the two differently. Spans and
+// metrics are not changed. Attributes set at the call site or through
+// WithContextAttributes take precedence over these on key clashes; the
+// trace and span IDs are record fields, not attributes, and are unaffected.
+// Repeated calls accumulate.
+func WithLogAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.logAttributes = append(c.logAttributes, attrs...)
+	}
+}
+
+// staticAttributesLogProcessor adds attrs to log records that do not already
+// carry the key. It must be registered after contextAttributesLogProcessor
+// and before the exporting processor.
+type staticAttributesLogProcessor struct {
+	attrs []log.KeyValue
+}
+
+func newStaticAttributesLogProcessor(attrs []attribute.KeyValue) staticAttributesLogProcessor {
+	p := staticAttributesLogProcessor{attrs: make([]log.KeyValue, len(attrs))}
+	for i, kv := range attrs {
+		p.attrs[i] = log.KeyValueFromAttribute(kv)
+	}
+	return p
+}
+
+func (p staticAttributesLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	set := make(map[string]bool, r.AttributesLen())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		set[kv.Key] = true
+		return true
+	})
+	for _, kv := range p.attrs {
+		if !set[kv.Key] {
+			r.AddAttributes(kv)
+			set[kv.Key] = true
+		}
+	}
+	return nil
+}
+
+func (staticAttributesLogProcessor) Shutdown(context.Context) error   { return nil }
+func (staticAttributesLogProcessor) ForceFlush(context.Context) error { return nil }
+
+// flushOnErrorProcessor passes records on to the wrapped processor and
+// flushes it after an error record, which is then already queued.
+type flushOnErrorProcessor struct {
+	sdklog.Processor
+
+	mu      sync.Mutex
+	running bool
+	again   bool
+}
+
+func (p *flushOnErrorProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	err := p.Processor.OnEmit(ctx, r)
+	if r.Severity() < log.SeverityError {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		p.again = true
+		return err
+	}
+	p.running = true
+	go p.flush()
+	return err
+}
+
+// flush flushes until no error records arrived during the last flush.
+func (p *flushOnErrorProcessor) flush() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultExportTimeout)
+		_ = p.Processor.ForceFlush(ctx)
+		cancel()
+
+		p.mu.Lock()
+		if !p.again {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		p.again = false
+		p.mu.Unlock()
+	}
+}
+
+// payloadLimitExporter splits log batches so each export stays under limit.
+// The OTLP exporter sends each resource and scope once per request, so
+// records are grouped by scope before splitting.
+type payloadLimitExporter struct {
+	sdklog.Exporter
+	limit int
+}
+
+// Export sends every chunk even if an earlier one fails, so a record the
+// collector rejects for its size only loses its own chunk.
+func (e *payloadLimitExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	var errs []error
+	start, size, copied := 0, 0, false
+	if !sortedByScope(records) {
+		// Keep records of a scope together so the split requests repeat each
+		// scope (and its attributes) as rarely as possible.
+		records, copied = slices.Clone(records), true
+		slices.SortStableFunc(records, compareScope)
+	}
+	for i := range records {
+		n := recordSize(&records[i])
+		if n > e.limit {
+			// The SDK owns the records slice; copy it before replacing entries.
+			if !copied {
+				records, copied = slices.Clone(records), true
+			}
+			records[i] = truncateRecord(records[i], n-e.limit)
+			n = recordSize(&records[i])
+		}
+		if size+n > e.limit && i > start {
+			errs = append(errs, e.Exporter.Export(ctx, records[start:i]))
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(records) {
+		errs = append(errs, e.Exporter.Export(ctx, records[start:]))
+	}
+	return errors.Join(errs...)
+}
+
+// sortedByScope reports whether records of the same instrumentation scope
+// are already contiguous.
+func sortedByScope(records []sdklog.Record) bool {
+	seen := map[instrumentation.Scope]bool{}
+	for i := range records {
+		scope := records[i].InstrumentationScope()
+		if i > 0 && scope == records[i-1].InstrumentationScope() {
+			continue
+		}
+		if seen[scope] {
+			return false
+		}
+		seen[scope] = true
+	}
+	return true
+}
+
+// compareScope orders records by instrumentation scope, grouping equal
+// scopes together.
+func compareScope(a, b sdklog.Record) int {
+	sa, sb := a.InstrumentationScope(), b.InstrumentationScope()
+	return cmp.Or(
+		cmp.Compare(sa.Name, sb.Name),
+		cmp.Compare(sa.Version, sb.Version),
+		cmp.Compare(sa.SchemaURL, sb.SchemaURL),
+		cmp.Compare(sa.Attributes.Encoded(attribute.DefaultEncoder()), sb.Attributes.Encoded(attribute.DefaultEncoder())),
+	)
+}
+
+// truncateRecord returns a copy of r with its string body shortened by at
+// least excess bytes, cut at a rune boundary so the body stays valid UTF-8,
+// which protobuf requires of strings. Records with other body kinds are
+// returned unchanged.
+func truncateRecord(r sdklog.Record, excess int) sdklog.Record {
+	body := r.Body()
+	if body.Kind() != log.KindString {
+		return r
+	}
+	s := body.AsString()
+	keep := len(s) - excess - len(truncatedSuffix)
+	if keep < 0 {
+		keep = 0
+	}
+	for keep > 0 && !utf8.RuneStart(s[keep]) {
+		keep--
+	}
+	r = r.Clone()
+	r.SetBody(log.StringValue(s[:keep] + truncatedSuffix))
+	return r
+}
+
+// recordSize approximates the encoded size of r in bytes.
+func recordSize(r *sdklog.Record) int {
+	n := recordOverheadBytes + len(r.SeverityText()) + len(r.EventName()) + valueSize(r.Body())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		n += len(kv.Key) + valueSize(kv.Value)
+		return true
+	})
+	return n
+}
+
+func valueSize(v log.Value) int {
+	switch v.Kind() {
+	case log.KindString:
+		return len(v.AsString())
+	case log.KindBytes:
+		return len(v.AsBytes())
+	case log.KindSlice:
+		n := 0
+		for _, e := range v.AsSlice() {
+			n += valueSize(e)
+		}
+		return n
+	case log.KindMap:
+		n := 0
+		for _, kv := range v.AsMap() {
+			n += len(kv.Key) + valueSize(kv.Value)
+		}
+		return n
+	case log.KindEmpty:
+		return 0
+	default:
+		return 8
+	}
+}
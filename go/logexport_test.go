@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// recordingLogExporter records the batches it is given and, like a collector
+// enforcing a size limit, rejects those containing a record with a map body.
+type recordingLogExporter struct {
+	sdklog.Exporter
+	batches [][]sdklog.Record
+}
+
+func (e *recordingLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.batches = append(e.batches, records)
+	for _, r := range records {
+		if r.Body().Kind() == log.KindMap {
+			return errors.New("request too large")
+		}
+	}
+	return nil
+}
+
+func stringRecord(body string) sdklog.Record {
+	var r sdklog.Record
+	r.SetBody(log.StringValue(body))
+	return r
+}
+
+func TestTruncateRecordKeepsValidUTF8(t *testing.T) {
+	body := strings.Repeat("日本語", 100)
+	for excess := 1; excess <= 20; excess++ {
+		r := truncateRecord(stringRecord(body), excess)
+		got := r.Body().AsString()
+		if !utf8.ValidString(got) {
+			t.Fatalf("excess %d: truncated body is not valid UTF-8: %q", excess, got)
+		}
+		if !strings.HasSuffix(got, truncatedSuffix) {
+			t.Errorf("excess %d: body %q lacks the truncation suffix", excess, got)
+		}
+		if shortened := len(body) - len(got); shortened < excess {
+			t.Errorf("excess %d: body shortened by only %d bytes", excess, shortened)
+		}
+	}
+}
+
+func TestTruncateRecordLeavesStructuredBodies(t *testing.T) {
+	var r sdklog.Record
+	r.SetBody(log.MapValue(log.String("stack", strings.Repeat("x", 1000))))
+	got := truncateRecord(r, 500)
+	if !got.Body().Equal(r.Body()) {
+		t.Errorf("map body changed to %v", got.Body())
+	}
+}
+
+func TestPayloadLimitExporterSplitsBatches(t *testing.T) {
+	next := &recordingLogExporter{}
+	e := &payloadLimitExporter{Exporter: next, limit: 3 * (recordOverheadBytes + 10)}
+	records := make([]sdklog.Record, 7)
+	for i := range records {
+		records[i] = stringRecord(strings.Repeat("a", 10))
+	}
+	if err := e.Export(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for _, b := range next.batches {
+		sizes = append(sizes, len(b))
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Errorf("batch sizes = %v, want [3 3 1]", sizes)
+	}
+}
+
+func TestPayloadLimitExporterContinuesAfterRejectedRecord(t *testing.T) {
+	huge := log.MapValue(log.String("dump", strings.Repeat("x", 1000)))
+	var oversized sdklog.Record
+	oversized.SetBody(huge)
+
+	next := &recordingLogExporter{}
+	e := &payloadLimitExporter{Exporter: next, limit: 200}
+	err := e.Export(context.Background(), []sdklog.Record{stringRecord("before"), oversized, stringRecord("after")})
+	if err == nil {
+		t.Fatal("Export returned nil, want the rejection of the oversized record")
+	}
+	if len(next.batches) != 3 {
+		t.Fatalf("got %d requests, want 3 (before, oversized, after)", len(next.batches))
+	}
+	if last := next.batches[2]; len(last) != 1 || last[0].Body().AsString() != "after" {
+		t.Errorf("last request = %v, want the record after the rejected one", last)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// logLevel is the minimum level of records logged through GetLogger. It
+// starts at the lowest OpenTelemetry severity, TRACE, so nothing is filtered
+// until it is changed through LogLevelHandler.
+var logLevel = func() *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(severityNames["trace"])
+	return v
+}()
+
+// levelHandler drops records below a level that can change at runtime.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level.Level() {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// logLevelConfig holds the settings of LogLevelHandler.
+type logLevelConfig struct {
+	authorize func(*http.Request) bool
+}
+
+// LogLevelOption customizes the handler created by LogLevelHandler.
+type LogLevelOption func(*logLevelConfig)
+
+// WithLogLevelAuth sets the check a PUT request must pass to change the log
+// level, e.g. comparing a bearer token or restricting callers to loopback.
+// Without it, the level cannot be changed.
+func WithLogLevelAuth(authorize func(*http.Request) bool) LogLevelOption {
+	return func(c *logLevelConfig) {
+		c.authorize = authorize
+	}
+}
+
+// LogLevelHandler serves the minimum level of the logs written through
+// GetLogger, so it can be raised or lowered without a redeploy. The level
+// applies to every log sink, including the console exporter and
+// WithSyslogSink; records below it are dropped. It starts at trace, i.e. all
+// records pass.
+//
+//   - GET returns the current level as text, e.g. "info".
+//   - PUT sets the level from the request body: one of trace, debug, info,
+//     notice, warn, error, critical or fatal (any case), or a slog level
+//     such as "DEBUG+2". It responds with the new level, 400 for an invalid
+//     level and 403 unless the WithLogLevelAuth check accepts the request.
+//   - Other methods get 405.
+//
+// Mount it on an internal port or path, e.g.
+//
+//	mux.Handle("/debug/loglevel", LogLevelHandler(WithLogLevelAuth(isAdmin)))
+func LogLevelHandler(opts ...LogLevelOption) http.Handler {
+	cfg := &logLevelConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			if cfg.authorize == nil || !cfg.authorize(r) {
+				http.Error(w, "changing the log level is not allowed", http.StatusForbidden)
+				return
+			}
+			body, err := io.ReadAll(io.LimitReader(r.Body, 64))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, ok := parseLogLevel(strings.TrimSpace(string(body)))
+			if !ok {
+				http.Error(w, "invalid log level, use trace, debug, info, notice, warn, error, critical or fatal", http.StatusBadRequest)
+				return
+			}
+			if old := logLevel.Level(); old != level {
+				// Logged before the change so raising the level does not
+				// hide it.
+				appLogger.InfoContext(r.Context(), "log level changed",
+					"from", logLevelName(old), "to", logLevelName(level), "remote_addr", r.RemoteAddr)
+				logLevel.Set(level)
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, logLevelName(logLevel.Level())+"\n")
+	})
+}
+
+// parseLogLevel parses a severity name or slog level.
+func parseLogLevel(s string) (slog.Level, bool) {
+	if level, ok := severityNames[strings.ToLower(s)]; ok {
+		return level, true
+	}
+	var level slog.Level
+	return level, level.UnmarshalText([]byte(s)) == nil
+}
+
+// logLevelName returns the severity name of level, or its slog name if it
+// has none.
+func logLevelName(level slog.Level) string {
+	for _, name := range []string{"trace", "debug", "info", "notice", "warn", "error", "critical", "fatal"} {
+		if severityNames[name] == level {
+			return name
+		}
+	}
+	return level.String()
+}
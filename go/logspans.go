@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithLogSpanEvents adds a span event to the active span for every log
+// record at level or above that is logged with a context carrying a span,
+// e.g. logger.ErrorContext(ctx, ...). The event is named after the log
+// message and carries the record's attributes, so the error shows up in the
+// trace view. Records at slog.LevelError or above also set the span status to
+// Error with the message as description. The log record is still exported
+// as usual.
+func WithLogSpanEvents(level slog.Level) Option {
+	return func(c *config) {
+		c.logSpanEvents = true
+		c.logSpanEventLevel = level
+	}
+}
+
+// spanEventHandler mirrors log records at or above level onto the active
+// span.
+type spanEventHandler struct {
+	slog.Handler
+	level  slog.Level
+	attrs  []attribute.KeyValue
+	prefix string
+}
+
+func (h spanEventHandler) Handle(ctx context.Context, r slog.Record) error {
+	if span := trace.SpanFromContext(ctx); r.Level >= h.level && span.IsRecording() {
+		attrs := append(slices.Clip(h.attrs), attribute.String("log.severity", r.Level.String()))
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = appendSlogAttr(attrs, h.prefix, a)
+			return true
+		})
+		span.AddEvent(r.Message, trace.WithTimestamp(r.Time), trace.WithAttributes(attrs...))
+		if r.Level >= slog.LevelError {
+			span.SetStatus(codes.Error, r.Message)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h spanEventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := slices.Clip(h.attrs)
+	for _, a := range attrs {
+		merged = appendSlogAttr(merged, h.prefix, a)
+	}
+	return spanEventHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level, attrs: merged, prefix: h.prefix}
+}
+
+func (h spanEventHandler) WithGroup(name string) slog.Handler {
+	return spanEventHandler{Handler: h.Handler.WithGroup(name), level: h.level, attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// WithSampledOnly drops log records below level unless the span in the
+// log call's context is sampled, so sampled requests get detailed logs and
+// the rest only log at level and above. Use a context-aware logging call,
+// e.g. logger.DebugContext(ctx, ...); records logged without a context
+// have no span and are dropped below level.
+//
+// The filter applies to everything behind GetLogger: the OTLP exporter, the
+// console exporter selected with OTEL_LOGS_EXPORTER=console and
+// WithSyslogSink alike, so a local console shows the same records as the
+// backend. Logs from this package's own diagnostics are not affected.
+// Records are filtered on their slog level, before WithSeverityAttribute
+// can raise it.
+func WithSampledOnly(level slog.Level) Option {
+	return func(c *config) {
+		c.sampledOnly = true
+		c.sampledOnlyLevel = level
+	}
+}
+
+// sampledOnlyHandler drops records below level outside sampled traces.
+type sampledOnlyHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h sampledOnlyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level && !trace.SpanContextFromContext(ctx).IsSampled() {
+		return false
+	}
+	return h.Handler.Enabled(ctx, level)
+}
+
+func (h sampledOnlyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.level && !trace.SpanContextFromContext(ctx).IsSampled() {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h sampledOnlyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return sampledOnlyHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h sampledOnlyHandler) WithGroup(name string) slog.Handler {
+	return sampledOnlyHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// WithLogSpanStatus adds the status of the active span to log records
+// emitted while that span has an Error status, as otel.status_code ("ERROR")
+// and otel.status_description, so error logs carry the outcome of the
+// operation they belong to. Only a status set before the log call is seen,
+// e.g. by span.SetStatus or by a record logged with WithLogSpanEvents.
+// Records in spans that are not failed, or not recording, are unchanged. The
+// attributes are added next to the trace and span IDs the records are
+// correlated with, and come from the same context, so log with the
+// ...Context methods.
+func WithLogSpanStatus() Option {
+	return func(c *config) {
+		c.logSpanStatus = true
+	}
+}
+
+// spanStatusLogProcessor adds the status of failed spans to log records. It
+// must be registered before the exporting processor.
+type spanStatusLogProcessor struct{}
+
+func (spanStatusLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	span := trace.SpanFromContext(ctx)
+	if p, ok := span.(prioritySpan); ok {
+		span = p.Span
+	}
+	s, ok := span.(interface{ Status() sdktrace.Status })
+	if !ok || !span.IsRecording() {
+		return nil
+	}
+	if status := s.Status(); status.Code == codes.Error {
+		r.AddAttributes(
+			log.String("otel.status_code", "ERROR"),
+			log.String("otel.status_description", status.Description),
+		)
+	}
+	return nil
+}
+
+func (spanStatusLogProcessor) Shutdown(context.Context) error   { return nil }
+func (spanStatusLogProcessor) ForceFlush(context.Context) error { return nil }
+
+// appendSlogAttr appends a as span attributes, flattening groups into
+// dot-separated keys.
+func appendSlogAttr(attrs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	v := a.Value.Resolve()
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, ga := range v.Group() {
+			attrs = appendSlogAttr(attrs, prefix, ga)
+		}
+		return attrs
+	case slog.KindBool:
+		return append(attrs, attribute.Bool(key, v.Bool()))
+	case slog.KindInt64:
+		return append(attrs, attribute.Int64(key, v.Int64()))
+	case slog.KindFloat64:
+		return append(attrs, attribute.Float64(key, v.Float64()))
+	default:
+		return append(attrs, attribute.String(key, v.String()))
+	}
+}
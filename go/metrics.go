@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Manual metric export state, set when WithManualMetricReader is used.
+var (
+	manualReader   *sdkmetric.ManualReader
+	manualExporter sdkmetric.Exporter
+)
+
+// WithManualMetricReader replaces the periodic metric reader with a manual
+// one: metrics are only exported when CollectAndExport is called. The two
+// readers are mutually exclusive, so nothing is exported on an interval.
+func WithManualMetricReader() Option {
+	return func(c *config) {
+		c.manualMetricReader = true
+	}
+}
+
+// WithResetResilientCounters exports counters and histograms with delta
+// temporality, so each export carries only what was recorded since the
+// previous one. Cumulative series restart from zero when a process
+// restarts, which backends that ignore the series' start time read as a
+// negative rate; deltas have no running total to reset, so frequently
+// restarting workers report correct rates. Up-down counters stay
+// cumulative, as their value is a level rather than a rate.
+//
+// This is the same selection as
+// OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE=delta, which it
+// overrides, and applies to the console exporter and WithManualMetricReader
+// too. Cumulative export, the default, stays preferable for long-running
+// services, since a lost delta export loses its data points for good.
+func WithResetResilientCounters() Option {
+	return func(c *config) {
+		c.resetResilientCounters = true
+	}
+}
+
+// deltaTemporality selects delta temporality for all instruments but
+// up-down counters.
+func deltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// CollectAndExport collects all metrics and exports them immediately. It
+// requires WithManualMetricReader.
+func CollectAndExport(ctx context.Context) error {
+	if manualReader == nil {
+		return errors.New("manual metric reader not enabled, use WithManualMetricReader")
+	}
+	var rm metricdata.ResourceMetrics
+	if err := manualReader.Collect(ctx, &rm); err != nil {
+		return err
+	}
+	return manualExporter.Export(ctx, &rm)
+}
+
+// meter returns the meter of the Must* and Register* helpers: GetMeter's
+// after setup, and before it a meter of the global delegating provider,
+// named after the executable, whose instruments forward to the real
+// provider once setup installs it.
+func meter() metric.Meter {
+	if appMeter != nil {
+		return appMeter
+	}
+	return otel.Meter(filepath.Base(os.Args[0]))
+}
+
+// instrumentKey identifies a cached instrument by kind and name.
+type instrumentKey struct {
+	kind string
+	name string
+}
+
+// Instruments created by the Must* helpers, so that every caller asking for
+// the same instrument gets the identical value.
+var (
+	instrumentsMu sync.RWMutex
+	instruments   = map[instrumentKey]any{}
+)
+
+// cachedInstrument returns the instrument cached under kind and name,
+// creating it with create on first use. Concurrent first calls create the
+// instrument only once. It panics if create fails.
+func cachedInstrument[T any](kind, name string, create func() (T, error)) T {
+	key := instrumentKey{kind: kind, name: name}
+
+	instrumentsMu.RLock()
+	inst, ok := instruments[key]
+	instrumentsMu.RUnlock()
+	if ok {
+		return inst.(T)
+	}
+
+	instrumentsMu.Lock()
+	defer instrumentsMu.Unlock()
+	// Another goroutine may have created it while the lock was released.
+	if inst, ok := instruments[key]; ok {
+		return inst.(T)
+	}
+	created, err := create()
+	if err != nil {
+		panic(err)
+	}
+	instruments[key] = created
+	return created
+}
+
+// MustInt64Counter returns the int64 counter with the given name on the global
+// meter, creating it on first use. Options only apply when the instrument is
+// created. It panics if the instrument cannot be created.
+// Instruments created before setup start recording once it has run.
+func MustInt64Counter(name string, opts ...metric.Int64CounterOption) metric.Int64Counter {
+	return cachedInstrument("int64counter", name, func() (metric.Int64Counter, error) {
+		return meter().Int64Counter(name, opts...)
+	})
+}
+
+// MustFloat64Counter is the float64 variant of MustInt64Counter.
+func MustFloat64Counter(name string, opts ...metric.Float64CounterOption) metric.Float64Counter {
+	return cachedInstrument("float64counter", name, func() (metric.Float64Counter, error) {
+		return meter().Float64Counter(name, opts...)
+	})
+}
+
+// MustInt64Histogram is the int64 histogram variant of MustInt64Counter.
+func MustInt64Histogram(name string, opts ...metric.Int64HistogramOption) metric.Int64Histogram {
+	return cachedInstrument("int64histogram", name, func() (metric.Int64Histogram, error) {
+		return meter().Int64Histogram(name, opts...)
+	})
+}
+
+// MustFloat64Histogram is the float64 histogram variant of MustInt64Counter.
+func MustFloat64Histogram(name string, opts ...metric.Float64HistogramOption) metric.Float64Histogram {
+	return cachedInstrument("float64histogram", name, func() (metric.Float64Histogram, error) {
+		return meter().Float64Histogram(name, opts...)
+	})
+}
+
+// RegisterGauge creates an int64 observable gauge on the global meter and
+// registers observe as its callback. The returned function unregisters the
+// callback. Gauges registered before setup are observed once it has run.
+func RegisterGauge(name string, observe func() int64, opts ...metric.Int64ObservableGaugeOption) (func() error, error) {
+	m := meter()
+	gauge, err := m.Int64ObservableGauge(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(gauge, observe())
+		return nil
+	}, gauge)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Unregister, nil
+}
+
+// RegisterFloat64Gauge is the float64 variant of RegisterGauge.
+func RegisterFloat64Gauge(name string, observe func() float64, opts ...metric.Float64ObservableGaugeOption) (func() error, error) {
+	m := meter()
+	gauge, err := m.Float64ObservableGauge(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := m.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(gauge, observe())
+		return nil
+	}, gauge)
+	if err != nil {
+		return nil, err
+	}
+	return reg.Unregister, nil
+}
+
+// Measurement is one value for RecordBatch, created by Int64Add,
+// Float64Add, Int64Record or Float64Record.
+type Measurement struct {
+	record func(context.Context, metric.MeasurementOption)
+}
+
+// Int64Add adds value to counter when passed to RecordBatch.
+func Int64Add(counter metric.Int64Counter, value int64) Measurement {
+	return Measurement{func(ctx context.Context, opt metric.MeasurementOption) { counter.Add(ctx, value, opt) }}
+}
+
+// Float64Add is the float64 variant of Int64Add.
+func Float64Add(counter metric.Float64Counter, value float64) Measurement {
+	return Measurement{func(ctx context.Context, opt metric.MeasurementOption) { counter.Add(ctx, value, opt) }}
+}
+
+// Int64Record records value on histogram when passed to RecordBatch.
+func Int64Record(histogram metric.Int64Histogram, value int64) Measurement {
+	return Measurement{func(ctx context.Context, opt metric.MeasurementOption) { histogram.Record(ctx, value, opt) }}
+}
+
+// Float64Record is the float64 variant of Int64Record.
+func Float64Record(histogram metric.Float64Histogram, value float64) Measurement {
+	return Measurement{func(ctx context.Context, opt metric.MeasurementOption) { histogram.Record(ctx, value, opt) }}
+}
+
+// RecordBatch records several measurements that share attrs, e.g. the
+// metrics describing one event on a hot path. The OpenTelemetry Go API has
+// no batch recording call; the saving comes from building the attribute set
+// once instead of once per instrument, which is most of the cost of a
+// measurement with attributes. Build attrs with attribute.NewSet once and
+// reuse it where the attributes repeat.
+//
+// The measurements are not atomic: each instrument is updated on its own, so
+// an export running concurrently may include some of them and not others.
+// Only synchronous counters and histograms are supported; up-down counters
+// and gauges can be recorded directly with metric.WithAttributeSet(attrs).
+func RecordBatch(ctx context.Context, attrs attribute.Set, measurements ...Measurement) {
+	opt := metric.WithAttributeSet(attrs)
+	for _, m := range measurements {
+		m.record(ctx, opt)
+	}
+}
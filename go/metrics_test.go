@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMustInstrumentsConcurrent(t *testing.T) {
+	const goroutines = 64
+	names := []string{"test.concurrent.a", "test.concurrent.b", "test.concurrent.c"}
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		counters   = map[string]map[any]bool{}
+		histograms = map[string]map[any]bool{}
+	)
+	remember := func(m map[string]map[any]bool, name string, inst any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if m[name] == nil {
+			m[name] = map[any]bool{}
+		}
+		m[name][inst] = true
+	}
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, name := range names {
+				c := MustInt64Counter(name)
+				c.Add(context.Background(), 1)
+				remember(counters, name, c)
+
+				h := MustFloat64Histogram(name)
+				h.Record(context.Background(), float64(i))
+				remember(histograms, name, h)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range names {
+		if n := len(counters[name]); n != 1 {
+			t.Errorf("MustInt64Counter(%q) returned %d distinct instruments, want 1", name, n)
+		}
+		if n := len(histograms[name]); n != 1 {
+			t.Errorf("MustFloat64Histogram(%q) returned %d distinct instruments, want 1", name, n)
+		}
+	}
+}
+
+// eventInstruments are the five instruments an event on a hot path records.
+type eventInstruments struct {
+	requests metric.Int64Counter
+	bytes    metric.Int64Counter
+	cost     metric.Float64Counter
+	items    metric.Int64Histogram
+	duration metric.Float64Histogram
+}
+
+func newEventInstruments(tb testing.TB, reader sdkmetric.Reader) eventInstruments {
+	tb.Helper()
+	m := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	var (
+		inst eventInstruments
+		errs [5]error
+	)
+	inst.requests, errs[0] = m.Int64Counter("events")
+	inst.bytes, errs[1] = m.Int64Counter("event.bytes")
+	inst.cost, errs[2] = m.Float64Counter("event.cost")
+	inst.items, errs[3] = m.Int64Histogram("event.items")
+	inst.duration, errs[4] = m.Float64Histogram("event.duration")
+	for _, err := range errs {
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return inst
+}
+
+func (inst eventInstruments) batch() []Measurement {
+	return []Measurement{
+		Int64Add(inst.requests, 1),
+		Int64Add(inst.bytes, 512),
+		Float64Add(inst.cost, 0.25),
+		Int64Record(inst.items, 3),
+		Float64Record(inst.duration, 0.004),
+	}
+}
+
+var eventAttrs = []attribute.KeyValue{
+	attribute.String("tenant", "acme"),
+	attribute.String("route", "/checkout"),
+	attribute.Int("shard", 7),
+}
+
+func TestRecordBatch(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	inst := newEventInstruments(t, reader)
+	attrs := attribute.NewSet(eventAttrs...)
+	RecordBatch(context.Background(), attrs, inst.batch()...)
+	RecordBatch(context.Background(), attrs, inst.batch()...)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			got[m.Name] = float64(data.DataPoints[0].Value)
+			checkAttrs(t, m.Name, data.DataPoints[0].Attributes, attrs)
+		case metricdata.Sum[float64]:
+			got[m.Name] = data.DataPoints[0].Value
+			checkAttrs(t, m.Name, data.DataPoints[0].Attributes, attrs)
+		case metricdata.Histogram[int64]:
+			got[m.Name] = float64(data.DataPoints[0].Count)
+			checkAttrs(t, m.Name, data.DataPoints[0].Attributes, attrs)
+		case metricdata.Histogram[float64]:
+			got[m.Name] = float64(data.DataPoints[0].Count)
+			checkAttrs(t, m.Name, data.DataPoints[0].Attributes, attrs)
+		}
+	}
+	want := map[string]float64{"events": 2, "event.bytes": 1024, "event.cost": 0.5, "event.items": 2, "event.duration": 2}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s = %v, want %v", name, got[name], w)
+		}
+	}
+}
+
+func checkAttrs(t *testing.T, name string, got, want attribute.Set) {
+	t.Helper()
+	if !got.Equals(&want) {
+		t.Errorf("%s attributes = %v, want %v", name, got.ToSlice(), want.ToSlice())
+	}
+}
+
+// BenchmarkRecordBatch compares recording five measurements with their
+// attributes passed to each call to RecordBatch, building the attribute set
+// per event or reusing it.
+func BenchmarkRecordBatch(b *testing.B) {
+	ctx := context.Background()
+	inst := newEventInstruments(b, sdkmetric.NewManualReader())
+
+	b.Run("PerCall", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			inst.requests.Add(ctx, 1, metric.WithAttributes(eventAttrs...))
+			inst.bytes.Add(ctx, 512, metric.WithAttributes(eventAttrs...))
+			inst.cost.Add(ctx, 0.25, metric.WithAttributes(eventAttrs...))
+			inst.items.Record(ctx, 3, metric.WithAttributes(eventAttrs...))
+			inst.duration.Record(ctx, 0.004, metric.WithAttributes(eventAttrs...))
+		}
+	})
+	b.Run("RecordBatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			RecordBatch(ctx, attribute.NewSet(eventAttrs...), inst.batch()...)
+		}
+	})
+	b.Run("RecordBatchSharedSet", func(b *testing.B) {
+		attrs := attribute.NewSet(eventAttrs...)
+		b.ReportAllocs()
+		for b.Loop() {
+			RecordBatch(ctx, attrs, inst.batch()...)
+		}
+	})
+}
+
+func TestDeltaTemporalityAcrossCollections(t *testing.T) {
+	reader := sdkmetric.NewManualReader(sdkmetric.WithTemporalitySelector(deltaTemporality))
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	requests, err := meter.Int64Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflight, err := meter.Int64UpDownCounter("inflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func() map[string]int64 {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				sum := m.Data.(metricdata.Sum[int64])
+				for _, dp := range sum.DataPoints {
+					got[m.Name] += dp.Value
+				}
+			}
+		}
+		return got
+	}
+
+	ctx := context.Background()
+	requests.Add(ctx, 5)
+	inflight.Add(ctx, 5)
+	if got := collect(); got["requests"] != 5 || got["inflight"] != 5 {
+		t.Fatalf("first collection = %v, want 5 for both", got)
+	}
+	requests.Add(ctx, 3)
+	inflight.Add(ctx, -2)
+	// The counter reports what happened since the last collection, so a
+	// restart never reads as a drop; the up-down counter its current value.
+	if got := collect(); got["requests"] != 3 || got["inflight"] != 3 {
+		t.Errorf("second collection = %v, want requests 3 (delta) and inflight 3 (cumulative)", got)
+	}
+}
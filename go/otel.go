@@ -1,32 +1,330 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/metric"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// Global telemetry instances
+// Global telemetry instances. Until setupInstrumentation runs, the tracer and
+// logger buffer what they receive and replay it once the providers are ready.
 var (
-	appTracer trace.Tracer
+	appTracer trace.Tracer = bootstrapTracer{}
 	appMeter  metric.Meter
-	appLogger *slog.Logger
+	appLogger *slog.Logger = slog.New(&bootstrapHandler{})
+
+	appResource *resource.Resource
 )
 
+// config holds the settings that can be customized through Options.
+type config struct {
+	partialSuccessLogLevel slog.Level
+	partialSuccessRetries  int
+	b3Propagator           bool
+	jaegerPropagator       bool
+	schemaURL              string
+	maxPayloadBytes        int
+	debugExport            bool
+	resourceKeyRewrite     func(string) string
+	excludedResourceKeys   map[attribute.Key]bool
+	startupEvent           bool
+	logger                 *slog.Logger
+	unixSocket             string
+	views                  []sdkmetric.View
+	noAuth                 bool
+	traceStateSamplingKey  string
+	gitCommit              string
+	manualMetricReader     bool
+	detectors              []resource.Detector
+	samplingReason         bool
+	http2                  bool
+	openMetricsNaming      bool
+	ucumUnits              map[string]string
+	resourceMergePriority  ResourceMergePriority
+	breakerFailures        int
+	breakerCooldown        time.Duration
+	detectorTimeout        time.Duration
+	idGenerator            sdktrace.IDGenerator
+	flushOnError           bool
+	logAttributes          []attribute.KeyValue
+	setupAttempts          int
+	setupBackoff           time.Duration
+	tenantSamplingKey      string
+	tenantRatios           map[string]float64
+	tenantBaseRatio        float64
+	resourceTransforms     []func(*resource.Resource) (*resource.Resource, error)
+	logDedupWindow         time.Duration
+	logDedupKey            func(*sdklog.Record) string
+	exportFiles            map[string]*exportFile
+	samplerRulesFile       string
+	samplingRules          *rulesWatcher
+	logSpanStatus          bool
+	targetPackageRouter    func(RoutedRecord) string
+	targetPackageAttribute bool
+	severityAttribute      string
+	srv                    *srvResolver
+	goroutineID            bool
+	processMetrics         bool
+	heartbeat              bool
+	heartbeatInterval      time.Duration
+	resourceAttributes     []attribute.KeyValue
+	minSpanDuration        time.Duration
+	attributeAllowlists    map[string][]attribute.Key
+	logSpanEvents          bool
+	logSpanEventLevel      slog.Level
+	sampledOnly            bool
+	sampledOnlyLevel       slog.Level
+	httpClient             *http.Client
+	latencySampling        *tailSamplingProcessor
+	errorSampling          *tailSamplingProcessor
+	deploymentColor        string
+	deployID               string
+	syslog                 *syslogTarget
+	syslogSink             *syslogSink
+	cardinalityThreshold   int
+	cardinalityInterval    time.Duration
+	resourceMetricKeys     []attribute.Key
+	region                 string
+	selfTracing            bool
+	resetResilientCounters bool
+	logScopeName           string
+	logScopeVersion        string
+	compression            bool
+	compressionLevel       int
+	resourceSpanKeys       []attribute.Key
+	targetSpanRate         float64
+	buildInfo              bool
+	requestSigner          func(*http.Request) error
+	orphanSpanNames        map[string]bool
+	traceGroupedBatches    bool
+	traceGroupingWindow    time.Duration
+	exporterOverrides      map[string]exporterOptions
+
+	// Resolved from the environment by resolveConfig.
+	getenv          func(string) string
+	otlpEndpoint    string
+	signalEndpoints map[string]exporterOptions
+	bearerToken     string
+
+	// Base transports shared by the exporters, by endpoint scheme and host.
+	transports map[string]*http.Transport
+	// OTEL_RESOURCE_ATTRIBUTES keys removed from the resource, which the
+	// SDK adds back; see droppedEnvResourceKeys.
+	droppedEnvResourceKeys map[string]bool
+}
+
+// Option customizes the behavior of setupInstrumentation.
+type Option func(*config)
+
+// newConfig returns the default configuration with opts applied.
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		partialSuccessLogLevel: slog.LevelWarn,
+		schemaURL:              DefaultSchemaURL,
+		logger:                 slog.Default(),
+		detectorTimeout:        defaultDetectorTimeout,
+		getenv:                 os.Getenv,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// resolveConfig applies opts and fills in the settings that come from the
+// environment, reading variables only through getenv so the precedence
+// rules can be exercised without touching the process environment. Options
+// take precedence over per-signal environment variables, which take
+// precedence over general ones, which take precedence over defaults. It
+// fails on invalid option values or endpoints; the returned config is still
+// usable for logging the error.
+func resolveConfig(getenv func(string) string, opts ...Option) (*config, error) {
+	cfg := newConfig(opts...)
+	cfg.getenv = getenv
+
+	if cfg.compression {
+		if err := checkCompressionLevel(cfg.compressionLevel); err != nil {
+			return cfg, err
+		}
+	}
+	if err := checkExportFiles(cfg.exportFiles); err != nil {
+		return cfg, err
+	}
+
+	// Get OTLP endpoint from environment or use default
+	cfg.otlpEndpoint = getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if cfg.otlpEndpoint == "" {
+		cfg.otlpEndpoint = "http://localhost:4318"
+	}
+
+	// Per-signal endpoints are full URLs, signal path included, and override
+	// the general endpoint for their signal
+	for signal := range targetPackages {
+		name := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT"
+		endpoint := getenv(name)
+		if endpoint == "" {
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return cfg, fmt.Errorf("invalid %s %q", name, endpoint)
+		}
+		if cfg.signalEndpoints == nil {
+			cfg.signalEndpoints = map[string]exporterOptions{}
+		}
+		cfg.signalEndpoints[signal] = exporterOptions{endpointURL: endpoint, urlPath: cmp.Or(u.Path, "/")}
+	}
+
+	// Get bearer token from environment
+	if !cfg.noAuth {
+		cfg.bearerToken = getenv("OTEL_EXPORTER_OTLP_BEARER_TOKEN")
+	}
+
+	// Enable export debug logging from environment
+	if debug, _ := strconv.ParseBool(getenv("OTEL_DEBUG")); debug {
+		cfg.debugExport = true
+	}
+
+	cfg.gitCommit = resolveGitCommit(cfg)
+	if cfg.deploymentColor == "" {
+		cfg.deploymentColor = getenv("DEPLOYMENT_COLOR")
+	}
+	if cfg.deployID == "" {
+		cfg.deployID = getenv("DEPLOY_ID")
+	}
+	if cfg.region == "" {
+		cfg.region = cmp.Or(getenv("CLOUD_REGION"), getenv("REGION"))
+	}
+	return cfg, nil
+}
+
+// WithNoAuth omits the Authorization header from every export request, even
+// when OTEL_EXPORTER_OTLP_BEARER_TOKEN or OTEL_EXPORTER_OTLP_HEADERS provide
+// one. Use it for collectors that authenticate by network policy and reject
+// unexpected credentials.
+func WithNoAuth() Option {
+	return func(c *config) {
+		c.noAuth = true
+	}
+}
+
+// WithInternalLogger routes the setup's own diagnostic messages (setup
+// progress, shutdown, export errors) to logger. Defaults to slog.Default().
+// Application logs still go through GetLogger.
+func WithInternalLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithLogScope sets the instrumentation scope of the records logged through
+// GetLogger. It defaults to the service name followed by "/log", so logs
+// are grouped apart from spans and metrics, whose scope is the service
+// name. A good scope names the code that logs, e.g. the application's
+// module path, with its release as version; version may be empty.
+func WithLogScope(name, version string) Option {
+	return func(c *config) {
+		c.logScopeName = name
+		c.logScopeVersion = version
+	}
+}
+
+// newLogHandler returns the OpenTelemetry bridge handler for the records
+// logged through GetLogger, under the scope chosen with WithLogScope.
+func newLogHandler(cfg *config, serviceName string) *otelslog.Handler {
+	return otelslog.NewHandler(logScopeName(cfg, serviceName), otelslog.WithVersion(cfg.logScopeVersion))
+}
+
+// logScopeName returns the scope name chosen with WithLogScope, or its
+// default.
+func logScopeName(cfg *config, serviceName string) string {
+	if cfg.logScopeName != "" {
+		return cfg.logScopeName
+	}
+	return serviceName + "/log"
+}
+
+// WithDebugExportLogging logs every export attempt with its signal, item
+// count, endpoint, HTTP status and latency. This is verbose and meant for
+// diagnosing missing telemetry. Setting OTEL_DEBUG=true has the same effect.
+func WithDebugExportLogging() Option {
+	return func(c *config) {
+		c.debugExport = true
+	}
+}
+
+// WithPartialSuccessLogLevel sets the level used to log OTLP partial-success
+// responses, i.e. exports where the backend rejected some of the records.
+// Defaults to slog.LevelWarn.
+func WithPartialSuccessLogLevel(level slog.Level) Option {
+	return func(c *config) {
+		c.partialSuccessLogLevel = level
+	}
+}
+
+// WithPartialSuccessRetry re-sends an export request up to retries more
+// times while the backend answers with a partial success, i.e. rejects some
+// of its records. A partial success does not say which records were
+// rejected, so the whole request is sent again: records the backend already
+// accepted are duplicated unless it deduplicates them. Use it only for
+// backends that reject records transiently, e.g. under per-tenant rate
+// limits; invalid or oversized records are rejected on every attempt. The
+// records still rejected after the last attempt are reported as without
+// retries. Defaults to 0, no retries.
+func WithPartialSuccessRetry(retries int) Option {
+	return func(c *config) {
+		c.partialSuccessRetries = retries
+	}
+}
+
+// TargetPackageKey is the resource attribute recording the Observe target
+// package a signal is sent to, when WithTargetPackageAttribute is enabled.
+const TargetPackageKey = attribute.Key("observe.target_package")
+
+// WithTargetPackageAttribute adds observe.target_package to each signal's
+// resource, set to the x-observe-target-package header its exporter sends
+// ("Tracing", "Metrics" or "Logs"), to confirm in Observe where telemetry
+// was routed. Spans, metrics and log records all carry it through their
+// resource. GetResource still returns the shared resource without it.
+func WithTargetPackageAttribute() Option {
+	return func(c *config) {
+		c.targetPackageAttribute = true
+	}
+}
+
+// withTargetPackage returns res with observe.target_package set to
+// targetPackage if WithTargetPackageAttribute is enabled.
+func withTargetPackage(cfg *config, res *resource.Resource, targetPackage string) (*resource.Resource, error) {
+	if !cfg.targetPackageAttribute {
+		return res, nil
+	}
+	return resource.Merge(res, resource.NewSchemaless(TargetPackageKey.String(targetPackage)))
+}
+
 // buildOTLPHeaders creates the standard headers for OTLP exporters.
 func buildOTLPHeaders(targetPackage, bearerToken string) map[string]string {
 	headers := map[string]string{
@@ -38,140 +336,472 @@ func buildOTLPHeaders(targetPackage, bearerToken string) map[string]string {
 	return headers
 }
 
+// exporterOptions is the OTLP exporter configuration shared by all signals.
+// newOTLPExporter translates it into the options of each exporter package,
+// so a new setting only has to be added here and in the otlpSignal
+// translations.
+type exporterOptions struct {
+	endpointURL string
+	urlPath     string
+	headers     map[string]string
+	client      *http.Client
+	// probeClient sends the probes of WithSetupRetry, bypassing the
+	// transports that observe exports.
+	probeClient *http.Client
+}
+
+// targetPackages maps each signal to the Observe target package it is sent
+// to.
+var targetPackages = map[string]string{
+	signalTraces:  "Tracing",
+	signalMetrics: "Metrics",
+	signalLogs:    "Logs",
+}
+
+// buildExporterOptions returns the OTLP exporter configuration for signal.
+// Only call it when an OTLP exporter is created: the client it builds
+// registers export health instruments.
+func buildExporterOptions(cfg *config, signal, otlpEndpoint, bearerToken string) exporterOptions {
+	exp := exporterOptions{
+		endpointURL: otlpEndpoint,
+		urlPath:     "/v1/" + signal,
+		headers:     buildOTLPHeaders(targetPackages[signal], bearerToken),
+	}
+	if o, ok := cfg.signalEndpoints[signal]; ok {
+		exp.endpointURL, exp.urlPath = o.endpointURL, o.urlPath
+	}
+	// Per-signal settings from a configuration file
+	if o, ok := cfg.exporterOverrides[signal]; ok {
+		if o.endpointURL != "" {
+			exp.endpointURL, exp.urlPath = o.endpointURL, o.urlPath
+		}
+		maps.Copy(exp.headers, o.headers)
+	}
+	exp.client, exp.probeClient = newExporterClient(signal, exp.endpointURL, cfg)
+	return exp
+}
+
+// warnIfGRPCPort logs a warning when the endpoint uses 4317, the standard
+// OTLP/gRPC port, since the exporters here speak OTLP/HTTP. Connections to a
+// gRPC listener fail with confusing connection-reset errors.
+func warnIfGRPCPort(logger *slog.Logger, otlpEndpoint string) {
+	u, err := url.Parse(otlpEndpoint)
+	if err != nil || u.Port() != "4317" {
+		return
+	}
+	logger.Warn("OTEL_EXPORTER_OTLP_ENDPOINT uses port 4317, the OTLP/gRPC port, but this setup exports OTLP/HTTP; "+
+		"use port 4318 (e.g. http://localhost:4318) or a collector with an OTLP/HTTP receiver on this port",
+		"endpoint", otlpEndpoint)
+}
+
 // setupTracing configures OpenTelemetry tracing with OTLP HTTP exporter.
-func setupTracing(ctx context.Context, res *resource.Resource, otlpEndpoint, bearerToken string) (*sdktrace.TracerProvider, error) {
-	headers := buildOTLPHeaders("Tracing", bearerToken)
-	traceExporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpointUrl(otlpEndpoint),
-		otlptracehttp.WithURLPath("/v1/traces"),
-		otlptracehttp.WithHeaders(headers),
-	)
+func setupTracing(ctx context.Context, cfg *config, res *resource.Resource, otlpEndpoint, bearerToken string) (*sdktrace.TracerProvider, error) {
+	kind, err := exporterSelection(cfg, signalTraces)
+	if err != nil || kind == exporterNone {
+		return nil, err
+	}
+	if res, err = withTargetPackage(cfg, res, targetPackages[signalTraces]); err != nil {
+		return nil, err
+	}
+
+	var traceExporter sdktrace.SpanExporter
+	if kind == exporterConsole {
+		markExported()
+		traceExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	} else {
+		traceExporter, err = newOTLPExporter(ctx, cfg, otlpTraces, signalTraces, otlpEndpoint, bearerToken)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
+	var bspOpts []sdktrace.BatchSpanProcessorOption
+	if cfg.traceGroupedBatches {
+		traceExporter = traceGroupingExporter{SpanExporter: traceExporter}
+		if cfg.traceGroupingWindow > 0 {
+			bspOpts = append(bspOpts, sdktrace.WithBatchTimeout(cfg.traceGroupingWindow))
+		}
+	}
+	var batcher sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(traceExporter, bspOpts...)
+	if cfg.minSpanDuration > 0 {
+		batcher = &minDurationProcessor{SpanProcessor: batcher, min: cfg.minSpanDuration}
+	}
+	batcher = withTailSampling(cfg, batcher)
+
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSpanProcessor(contextAttributesSpanProcessor{}),
+		sdktrace.WithSpanProcessor(newActiveSpansProcessor(cfg.logger)),
+		sdktrace.WithSpanProcessor(batcher),
 		sdktrace.WithResource(res),
-	)
+		sdktrace.WithSampler(newSampler(cfg)),
+	}
+	if cfg.idGenerator != nil {
+		tpOpts = append(tpOpts, sdktrace.WithIDGenerator(cfg.idGenerator))
+	}
+	if cfg.goroutineID {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(goroutineIDSpanProcessor{}))
+	}
+	if len(cfg.resourceSpanKeys) > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newResourceAttributesSpanProcessor(res, cfg.resourceSpanKeys)))
+	}
+	tp := sdktrace.NewTracerProvider(tpOpts...)
 	otel.SetTracerProvider(tp)
 
 	return tp, nil
 }
 
 // setupMetrics configures OpenTelemetry metrics with OTLP HTTP exporter.
-func setupMetrics(ctx context.Context, res *resource.Resource, otlpEndpoint, bearerToken string) (*sdkmetric.MeterProvider, error) {
-	headers := buildOTLPHeaders("Metrics", bearerToken)
-	metricExporter, err := otlpmetrichttp.New(ctx,
-		otlpmetrichttp.WithEndpointUrl(otlpEndpoint),
-		otlpmetrichttp.WithURLPath("/v1/metrics"),
-		otlpmetrichttp.WithHeaders(headers),
-	)
+func setupMetrics(ctx context.Context, cfg *config, res *resource.Resource, otlpEndpoint, bearerToken string) (*sdkmetric.MeterProvider, error) {
+	kind, err := exporterSelection(cfg, signalMetrics)
+	if err != nil || kind == exporterNone {
+		return nil, err
+	}
+	if res, err = withTargetPackage(cfg, res, targetPackages[signalMetrics]); err != nil {
+		return nil, err
+	}
+
+	var metricExporter sdkmetric.Exporter
+	if kind == exporterConsole {
+		markExported()
+		opts := []stdoutmetric.Option{stdoutmetric.WithPrettyPrint()}
+		if cfg.resetResilientCounters {
+			opts = append(opts, stdoutmetric.WithTemporalitySelector(deltaTemporality))
+		}
+		metricExporter, err = stdoutmetric.New(opts...)
+	} else {
+		var opts []otlpmetrichttp.Option
+		if cfg.resetResilientCounters {
+			opts = append(opts, otlpmetrichttp.WithTemporalitySelector(deltaTemporality))
+		}
+		metricExporter, err = newOTLPExporter(ctx, cfg, otlpMetrics, signalMetrics, otlpEndpoint, bearerToken, opts...)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	mp := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+	if len(cfg.resourceMetricKeys) > 0 {
+		metricExporter = newResourceAttributesExporter(metricExporter, res, cfg.resourceMetricKeys)
+	}
+
+	var reader sdkmetric.Reader
+	if cfg.manualMetricReader {
+		// The periodic reader asks the exporter for its temporality; the
+		// manual reader has to be told.
+		manualReader = sdkmetric.NewManualReader(sdkmetric.WithTemporalitySelector(metricExporter.Temporality))
+		manualExporter = metricExporter
+		reader = manualReader
+	} else {
+		var readerOpts []sdkmetric.PeriodicReaderOption
+		if cfg.heartbeat && cfg.heartbeatInterval > 0 {
+			readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.heartbeatInterval))
+		}
+		reader = sdkmetric.NewPeriodicReader(metricExporter, readerOpts...)
+	}
+
+	mpOpts := []sdkmetric.Option{
+		sdkmetric.WithReader(reader),
 		sdkmetric.WithResource(res),
-	)
+		sdkmetric.WithView(metricViews(cfg)...),
+	}
+	var monitor *cardinalityMonitor
+	if cfg.cardinalityThreshold > 0 && cfg.cardinalityInterval > 0 {
+		monitor = newCardinalityMonitor(cfg)
+		mpOpts = append(mpOpts, sdkmetric.WithReader(monitor.reader))
+	}
+	mp := sdkmetric.NewMeterProvider(mpOpts...)
 	otel.SetMeterProvider(mp)
+	if monitor != nil {
+		go monitor.run()
+	}
 
 	return mp, nil
 }
 
 // setupLogging configures OpenTelemetry logging with OTLP HTTP exporter and structured logging.
-func setupLogging(ctx context.Context, res *resource.Resource, otlpEndpoint, bearerToken, serviceName string) (*sdklog.LoggerProvider, error) {
-	headers := buildOTLPHeaders("Logs", bearerToken)
-	logExporter, err := otlploghttp.New(ctx,
-		otlploghttp.WithEndpointUrl(otlpEndpoint),
-		otlploghttp.WithURLPath("/v1/logs"),
-		otlploghttp.WithHeaders(headers),
-	)
+func setupLogging(ctx context.Context, cfg *config, res *resource.Resource, otlpEndpoint, bearerToken, serviceName string) (*sdklog.LoggerProvider, error) {
+	kind, err := exporterSelection(cfg, signalLogs)
+	if err != nil || kind == exporterNone {
+		return nil, err
+	}
+	if res, err = withTargetPackage(cfg, res, targetPackages[signalLogs]); err != nil {
+		return nil, err
+	}
+
+	var exporter sdklog.Exporter
+	if kind == exporterConsole {
+		markExported()
+		exporter, err = stdoutlog.New(stdoutlog.WithPrettyPrint())
+	} else {
+		exporter, err = newOTLPExporter(ctx, cfg, otlpLogs, signalLogs, otlpEndpoint, bearerToken)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	lp := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+	if cfg.maxPayloadBytes > 0 {
+		exporter = &payloadLimitExporter{Exporter: exporter, limit: cfg.maxPayloadBytes}
+	}
+
+	var batcher sdklog.Processor = sdklog.NewBatchProcessor(exporter)
+	if cfg.flushOnError {
+		// Inside dedup, so a run is flushed when it ends instead of being
+		// ended by the flush
+		batcher = &flushOnErrorProcessor{Processor: batcher}
+	}
+	if cfg.logDedupWindow > 0 {
+		batcher = newDedupProcessor(batcher, cfg.logDedupWindow, cfg.logDedupKey)
+	}
+	lpOpts := []sdklog.LoggerProviderOption{
+		sdklog.WithProcessor(contextAttributesLogProcessor{}),
 		sdklog.WithResource(res),
-	)
+	}
+	if len(cfg.logAttributes) > 0 {
+		lpOpts = append(lpOpts, sdklog.WithProcessor(newStaticAttributesLogProcessor(cfg.logAttributes)))
+	}
+	if cfg.logSpanStatus {
+		lpOpts = append(lpOpts, sdklog.WithProcessor(spanStatusLogProcessor{}))
+	}
+	lpOpts = append(lpOpts, sdklog.WithProcessor(batcher))
+	lp := sdklog.NewLoggerProvider(lpOpts...)
 	global.SetLoggerProvider(lp)
 
 	// Create structured logger that will send logs to OTLP
-	otelHandler := otelslog.NewHandler(serviceName)
+	var otelHandler slog.Handler = newLogHandler(cfg, serviceName)
+	if cfg.logSpanEvents {
+		otelHandler = spanEventHandler{Handler: otelHandler, level: cfg.logSpanEventLevel}
+	}
+	if cfg.syslog != nil {
+		var syslogHandler slog.Handler
+		syslogHandler, cfg.syslogSink = newSyslogHandler(cfg.syslog, serviceName, cfg.logger)
+		otelHandler = fanoutHandler{otelHandler, syslogHandler}
+	}
+	if cfg.sampledOnly {
+		otelHandler = sampledOnlyHandler{Handler: otelHandler, level: cfg.sampledOnlyLevel}
+	}
+	otelHandler = levelHandler{Handler: otelHandler, level: logLevel}
+	// Outermost, so the other handlers see the final level
+	if cfg.severityAttribute != "" {
+		otelHandler = severityHandler{Handler: otelHandler, key: cfg.severityAttribute}
+	}
 	appLogger = slog.New(otelHandler)
+	appEventLogger = lp.Logger(logScopeName(cfg, serviceName), log.WithInstrumentationVersion(cfg.logScopeVersion))
 
 	return lp, nil
 }
 
 // setupInstrumentation initializes OpenTelemetry with tracing, metrics, and logging.
 // Returns a cleanup function that should be called before application shutdown.
-func setupInstrumentation(serviceName string) func() {
-	ctx := context.Background()
+// A signal whose exporter cannot be initialized is disabled with a warning;
+// any other error panics. Use Setup to handle errors instead.
+func setupInstrumentation(serviceName string, opts ...Option) func() {
+	cleanup, err := Setup(serviceName, opts...)
+	if _, partial := err.(*SetupError); err != nil && !partial {
+		panic(err)
+	}
+	return cleanup
+}
 
-	// Get OTLP endpoint from environment or use default
-	otlpEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otlpEndpoint == "" {
-		otlpEndpoint = "http://localhost:4318"
+// Setup is like setupInstrumentation but returns errors instead of
+// panicking. If only some signals fail it returns a *SetupError together
+// with a working cleanup function: the failed signals are replaced by no-op
+// providers and the rest keep exporting. Other errors, such as an invalid
+// resource, leave instrumentation uninstalled.
+func Setup(serviceName string, opts ...Option) (func(), error) {
+	return SetupContext(context.Background(), serviceName, opts...)
+}
+
+// SetupContext is like Setup but stops when ctx is canceled or its deadline
+// passes, e.g. to bound startup time when resource detectors or SRV lookups
+// are slow. ctx only covers setup: it is not used for exporting, and
+// canceling it afterwards has no effect. If ctx ends during setup, the
+// providers created so far are shut down, their exporters' connections
+// closed, and an error wrapping ctx.Err() is returned with a no-op cleanup function. The
+// global providers then discard all telemetry until Setup is run again.
+func SetupContext(ctx context.Context, serviceName string, opts ...Option) (func(), error) {
+	return setup(ctx, serviceName, os.Getenv, opts...)
+}
+
+// setup implements SetupContext, reading the environment through getenv.
+func setup(ctx context.Context, serviceName string, getenv func(string) string, opts ...Option) (func(), error) {
+	start := time.Now()
+	cfg, err := resolveConfig(getenv, opts...)
+	if err != nil {
+		cfg.logger.Error("invalid OpenTelemetry options", "error", err)
+		return func() {}, err
 	}
+	otlpEndpoint, bearerToken := cfg.otlpEndpoint, cfg.bearerToken
 
-	// Get bearer token from environment
-	bearerToken := os.Getenv("OTEL_EXPORTER_OTLP_BEARER_TOKEN")
+	warnIfGRPCPort(cfg.logger, otlpEndpoint)
+
+	// Resolve SRV collector targets up front so problems show at startup
+	if cfg.srv != nil {
+		if targets, err := cfg.srv.resolve(ctx, cfg.logger); err != nil {
+			cfg.logger.Warn("SRV lookup failed, retrying on first export", "name", cfg.srv.name, "error", err)
+		} else {
+			cfg.logger.Info("resolved collector targets from SRV record", "name", cfg.srv.name, "targets", len(targets))
+		}
+	}
+
+	// Report errors from the SDK and exporters through the internal logger
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		cfg.logger.Error("OpenTelemetry error", "error", err)
+	}))
 
 	// Create resource with service identification
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(serviceName),
-			semconv.ServiceVersion("1.0.0"),
-		),
-	)
+	res, err := buildResource(ctx, cfg, serviceName)
+	if ctx.Err() != nil {
+		return abortSetup(ctx, cfg, func() {})
+	}
 	if err != nil {
-		slog.Error("failed to create resource", "error", err)
-		panic(err)
+		cfg.logger.Error("failed to create resource", "error", err)
+		return func() {}, err
+	}
+	appResource = res
+	cfg.droppedEnvResourceKeys = droppedEnvResourceKeys(res)
+
+	// Signals that fail are left on the global no-op providers
+	setupErr := &SetupError{}
+
+	// The cleanup function shuts down the providers created below. It uses a
+	// context that is not canceled with ctx so a canceled setup can still
+	// release what it created.
+	var (
+		tp *sdktrace.TracerProvider
+		mp *sdkmetric.MeterProvider
+		lp *sdklog.LoggerProvider
+	)
+	shutdownCtx := context.WithoutCancel(ctx)
+	cleanup := func() {
+		cfg.logger.Info("Shutting down OpenTelemetry instrumentation")
+		tracingEnabled.Store(false)
+		metricsEnabled.Store(false)
+		if cfg.samplingRules != nil {
+			cfg.samplingRules.Close()
+		}
+
+		if tp != nil {
+			if err := tp.Shutdown(shutdownCtx); err != nil {
+				cfg.logger.Error("failed to shutdown tracer provider", "error", err)
+			}
+		}
+		if manualReader != nil {
+			// The manual reader has no export loop to drain; push the final
+			// metrics before shutting the reader down.
+			if err := CollectAndExport(shutdownCtx); err != nil {
+				cfg.logger.Error("failed to export final metrics", "error", err)
+			}
+			if err := manualExporter.Shutdown(shutdownCtx); err != nil {
+				cfg.logger.Error("failed to shutdown metric exporter", "error", err)
+			}
+		}
+		if mp != nil {
+			if err := mp.Shutdown(shutdownCtx); err != nil {
+				cfg.logger.Error("failed to shutdown meter provider", "error", err)
+			}
+		}
+		if lp != nil {
+			if err := lp.Shutdown(shutdownCtx); err != nil {
+				cfg.logger.Error("failed to shutdown logger provider", "error", err)
+			}
+		}
+		if cfg.syslogSink != nil {
+			if err := cfg.syslogSink.Close(); err != nil {
+				cfg.logger.Error("failed to close syslog connection", "error", err)
+			}
+		}
+	}
+	if cfg.samplerRulesFile != "" {
+		if cfg.samplingRules, err = watchSamplingRules(cfg, cfg.samplerRulesFile); err != nil {
+			cfg.logger.Error("invalid OpenTelemetry options", "error", err)
+			return func() {}, err
+		}
 	}
 
 	// Setup tracing
-	tp, err := setupTracing(ctx, res, otlpEndpoint, bearerToken)
+	tp, err = retrySetup(ctx, cfg, signalTraces, func() (*sdktrace.TracerProvider, error) {
+		return setupTracing(ctx, cfg, res, otlpEndpoint, bearerToken)
+	})
+	if ctx.Err() != nil {
+		return abortSetup(ctx, cfg, cleanup)
+	}
 	if err != nil {
-		slog.Error("failed to setup tracing", "error", err)
-		panic(err)
+		cfg.logger.Warn("failed to setup tracing, traces are disabled", "error", err)
+		setupErr.Traces = err
 	}
+	tracingEnabled.Store(tp != nil)
 	appTracer = otel.Tracer(serviceName)
+	otel.SetTextMapPropagator(newPropagator(cfg))
 
 	// Setup metrics
-	mp, err := setupMetrics(ctx, res, otlpEndpoint, bearerToken)
+	mp, err = retrySetup(ctx, cfg, signalMetrics, func() (*sdkmetric.MeterProvider, error) {
+		return setupMetrics(ctx, cfg, res, otlpEndpoint, bearerToken)
+	})
+	if ctx.Err() != nil {
+		return abortSetup(ctx, cfg, cleanup)
+	}
 	if err != nil {
-		slog.Error("failed to setup metrics", "error", err)
-		panic(err)
+		cfg.logger.Warn("failed to setup metrics, metrics are disabled", "error", err)
+		setupErr.Metrics = err
 	}
+	metricsEnabled.Store(mp != nil)
 	appMeter = otel.Meter(serviceName)
+	if cfg.processMetrics && mp != nil {
+		if err := registerProcessMetrics(); err != nil {
+			cfg.logger.Warn("failed to register process metrics", "error", err)
+		}
+	}
+	if cfg.heartbeat && mp != nil {
+		if err := registerHeartbeat(); err != nil {
+			cfg.logger.Warn("failed to register heartbeat", "error", err)
+		}
+	}
 
 	// Setup logging
-	lp, err := setupLogging(ctx, res, otlpEndpoint, bearerToken, serviceName)
+	lp, err = retrySetup(ctx, cfg, signalLogs, func() (*sdklog.LoggerProvider, error) {
+		return setupLogging(ctx, cfg, res, otlpEndpoint, bearerToken, serviceName)
+	})
+	if ctx.Err() != nil {
+		return abortSetup(ctx, cfg, cleanup)
+	}
 	if err != nil {
-		slog.Error("failed to setup logging", "error", err)
-		panic(err)
+		cfg.logger.Warn("failed to setup logging, logs are disabled", "error", err)
+		setupErr.Logs = err
+	}
+	if lp == nil {
+		// Logging is disabled; log through the global no-op provider
+		appLogger = slog.New(newLogHandler(cfg, serviceName))
+	}
+
+	// Forward tracers and loggers handed out before setup and replay what they buffered
+	if replayed, dropped := bootstrap.install(appTracer, appLogger.Handler()); replayed > 0 || dropped > 0 {
+		cfg.logger.Info("replayed telemetry recorded before setup", "replayed", replayed, "dropped", dropped)
+	}
+
+	if cfg.selfTracing && tp != nil {
+		emitSetupSpan(ctx, start, setupErr)
 	}
 
-	appLogger.Info("OpenTelemetry instrumentation initialized",
+	cfg.logger.Info("OpenTelemetry instrumentation initialized",
 		"service", serviceName,
 		"endpoint", otlpEndpoint)
 
-	// Return cleanup function
-	return func() {
-		appLogger.Info("Shutting down OpenTelemetry instrumentation")
+	if cfg.startupEvent {
+		emitStartupEvent(ctx, serviceName)
+	}
 
-		if err := tp.Shutdown(ctx); err != nil {
-			slog.Error("failed to shutdown tracer provider", "error", err)
-		}
-		if err := mp.Shutdown(ctx); err != nil {
-			slog.Error("failed to shutdown meter provider", "error", err)
-		}
-		if err := lp.Shutdown(ctx); err != nil {
-			slog.Error("failed to shutdown logger provider", "error", err)
-		}
+	if setupErr.failed() {
+		return cleanup, setupErr
 	}
+	return cleanup, nil
+}
+
+// abortSetup releases what a setup canceled through ctx created by calling
+// cleanup and returns ctx's error.
+func abortSetup(ctx context.Context, cfg *config, cleanup func()) (func(), error) {
+	err := ctx.Err()
+	cfg.logger.Warn("OpenTelemetry setup canceled, shutting down providers created so far", "error", err)
+	cleanup()
+	return func() {}, fmt.Errorf("setup canceled: %w", err)
 }
 
 // GetTracer returns the global tracer instance.
@@ -186,8 +816,40 @@ func GetMeter() metric.Meter {
 	return appMeter
 }
 
+// MeterForScope returns a meter whose instrumentation scope is name and
+// version, for instrumentation libraries that should be identified on their
+// own rather than under the service name used by GetMeter. name is usually
+// the library's import path. The meter works before setup and starts
+// exporting once setupInstrumentation runs.
+func MeterForScope(name, version string) metric.Meter {
+	return otel.Meter(name, metric.WithInstrumentationVersion(version))
+}
+
+// TracerForScope is the tracing counterpart of MeterForScope.
+func TracerForScope(name, version string) trace.Tracer {
+	return otel.Tracer(name, trace.WithInstrumentationVersion(version))
+}
+
+// LoggerForScope is the logging counterpart of MeterForScope. Records go to
+// the same logger provider, and so carry the same resource, as GetLogger's,
+// but only through the OpenTelemetry bridge: options that wrap the
+// application's handler, such as WithLogSpanEvents, WithSampledOnly,
+// WithSeverityAttribute and WithSyslogSink, do not apply to them.
+func LoggerForScope(name, version string) *slog.Logger {
+	return slog.New(otelslog.NewHandler(name, otelslog.WithVersion(version)))
+}
+
 // GetLogger returns the global structured logger instance.
 // Call setupInstrumentation first.
 func GetLogger() *slog.Logger {
 	return appLogger
 }
+
+// LogForTrace logs msg at Info level through the global logger, correlated
+// with spanCtx instead of the span active in ctx, e.g. to log from a child
+// job about its parent job's trace. args are key-value pairs as for
+// slog.Logger.Info. Other values in ctx, such as context attributes, still
+// apply.
+func LogForTrace(ctx context.Context, spanCtx trace.SpanContext, msg string, args ...any) {
+	appLogger.InfoContext(trace.ContextWithSpanContext(ctx, spanCtx), msg, args...)
+}
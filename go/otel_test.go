@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	lognoop "go.opentelemetry.io/otel/log/noop"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// testConfig resolves opts against env instead of the process environment.
+func testConfig(t *testing.T, env map[string]string, opts ...Option) *config {
+	t.Helper()
+	cfg, err := resolveConfig(func(key string) string { return env[key] }, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// testCollector is an OTLP/HTTP endpoint recording the resources of the
+// telemetry exported to it, by signal.
+type testCollector struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	resources map[string][]*resourcepb.Resource
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	c := &testCollector{resources: map[string][]*resourcepb.Resource{}}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.handle))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *testCollector) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		body, err = decodeBody(body, r.Header.Get("Content-Encoding"))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var (
+		signal    string
+		resources []*resourcepb.Resource
+	)
+	switch r.URL.Path {
+	case "/v1/traces":
+		var req coltracepb.ExportTraceServiceRequest
+		err = proto.Unmarshal(body, &req)
+		for _, rs := range req.ResourceSpans {
+			resources = append(resources, rs.Resource)
+		}
+		signal = signalTraces
+	case "/v1/metrics":
+		var req colmetricpb.ExportMetricsServiceRequest
+		err = proto.Unmarshal(body, &req)
+		for _, rm := range req.ResourceMetrics {
+			resources = append(resources, rm.Resource)
+		}
+		signal = signalMetrics
+	case "/v1/logs":
+		var req collogspb.ExportLogsServiceRequest
+		err = proto.Unmarshal(body, &req)
+		for _, rl := range req.ResourceLogs {
+			resources = append(resources, rl.Resource)
+		}
+		signal = signalLogs
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.resources[signal] = append(c.resources[signal], resources...)
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+// resourceKeys returns the attribute keys of each resource received for
+// signal.
+func (c *testCollector) resourceKeys(signal string) []map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []map[string]bool
+	for _, res := range c.resources[signal] {
+		m := map[string]bool{}
+		for _, kv := range res.GetAttributes() {
+			m[kv.Key] = true
+		}
+		keys = append(keys, m)
+	}
+	return keys
+}
+
+func TestResolveConfigEndpointPrecedence(t *testing.T) {
+	fileOverride := func(c *config) {
+		c.exporterOverrides = map[string]exporterOptions{
+			signalTraces: {endpointURL: "https://file.example/otlp/v1/traces", urlPath: "/otlp/v1/traces"},
+		}
+	}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		opts     []Option
+		wantURL  string
+		wantPath string
+	}{
+		{
+			name:     "default",
+			wantURL:  "http://localhost:4318",
+			wantPath: "/v1/traces",
+		},
+		{
+			name:     "general env",
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector.example:4318"},
+			wantURL:  "https://collector.example:4318",
+			wantPath: "/v1/traces",
+		},
+		{
+			name: "per-signal env over general env",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://collector.example:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces.example/custom/traces",
+			},
+			wantURL:  "https://traces.example/custom/traces",
+			wantPath: "/custom/traces",
+		},
+		{
+			name: "other signal's env ignored",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":      "https://collector.example:4318",
+				"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT": "https://logs.example/v1/logs",
+			},
+			wantURL:  "https://collector.example:4318",
+			wantPath: "/v1/traces",
+		},
+		{
+			name: "option over per-signal env",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://collector.example:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces.example/custom/traces",
+			},
+			opts:     []Option{fileOverride},
+			wantURL:  "https://file.example/otlp/v1/traces",
+			wantPath: "/otlp/v1/traces",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.env, tt.opts...)
+			exp := buildExporterOptions(cfg, signalTraces, cfg.otlpEndpoint, cfg.bearerToken)
+			if exp.endpointURL != tt.wantURL || exp.urlPath != tt.wantPath {
+				t.Errorf("endpoint = %s with path %s, want %s with path %s", exp.endpointURL, exp.urlPath, tt.wantURL, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestResolveConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		opts  []Option
+		field func(*config) string
+		want  string
+	}{
+		{"region default", nil, nil, regionOf, ""},
+		{"region general env", map[string]string{"REGION": "eu-west-1"}, nil, regionOf, "eu-west-1"},
+		{"region specific env over general env", map[string]string{"REGION": "eu-west-1", "CLOUD_REGION": "us-east-1"}, nil, regionOf, "us-east-1"},
+		{"region option over env", map[string]string{"REGION": "eu-west-1", "CLOUD_REGION": "us-east-1"}, []Option{WithRegion("ap-south-1")}, regionOf, "ap-south-1"},
+
+		{"commit general env", map[string]string{"GIT_COMMIT": "1111111"}, nil, commitOf, "1111111"},
+		{"commit specific env over general env", map[string]string{"GIT_COMMIT": "1111111", "VCS_REVISION": "2222222"}, nil, commitOf, "2222222"},
+		{"commit option over env", map[string]string{"GIT_COMMIT": "1111111", "VCS_REVISION": "2222222"}, []Option{WithGitCommit("3333333")}, commitOf, "3333333"},
+
+		{"deployment color default", nil, nil, colorOf, ""},
+		{"deployment color env", map[string]string{"DEPLOYMENT_COLOR": "green"}, nil, colorOf, "green"},
+		{"deployment color option over env", map[string]string{"DEPLOYMENT_COLOR": "green"}, []Option{WithDeploymentColor("blue")}, colorOf, "blue"},
+
+		{"bearer token env", map[string]string{"OTEL_EXPORTER_OTLP_BEARER_TOKEN": "secret"}, nil, tokenOf, "secret"},
+		{"bearer token dropped by option", map[string]string{"OTEL_EXPORTER_OTLP_BEARER_TOKEN": "secret"}, []Option{WithNoAuth()}, tokenOf, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.field(testConfig(t, tt.env, tt.opts...)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func regionOf(c *config) string { return c.region }
+func commitOf(c *config) string { return c.gitCommit }
+func colorOf(c *config) string  { return c.deploymentColor }
+func tokenOf(c *config) string  { return c.bearerToken }
+
+func TestResolveConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		opts []Option
+	}{
+		{"per-signal endpoint without scheme", map[string]string{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "collector:4318/v1/metrics"}, nil},
+		{"compression level out of range", nil, []Option{WithCompressionLevel(42)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := resolveConfig(func(key string) string { return tt.env[key] }, tt.opts...)
+			if err == nil {
+				t.Fatal("resolveConfig returned no error")
+			}
+			if cfg == nil || cfg.logger == nil {
+				t.Error("resolveConfig returned no config to log the error with")
+			}
+		})
+	}
+}
+
+// resetGlobalProviders installs no-op global providers for the test and
+// restores the previous ones afterwards.
+func resetGlobalProviders(t *testing.T) {
+	tp, mp, lp := otel.GetTracerProvider(), otel.GetMeterProvider(), global.GetLoggerProvider()
+	otel.SetTracerProvider(tracenoop.NewTracerProvider())
+	otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	global.SetLoggerProvider(lognoop.NewLoggerProvider())
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tp)
+		otel.SetMeterProvider(mp)
+		global.SetLoggerProvider(lp)
+	})
+}
+
+func TestSetupCanceledBetweenSignals(t *testing.T) {
+	tests := []struct {
+		cancelAt   string
+		wantLogger bool
+	}{
+		{cancelAt: "OTEL_METRICS_EXPORTER"},
+		{cancelAt: "OTEL_LOGS_EXPORTER", wantLogger: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cancelAt, func(t *testing.T) {
+			resetGlobalProviders(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			defer srv.Close()
+
+			// Each signal's setup reads its exporter selection first; cancel
+			// when the one of tt.cancelAt is read, after the earlier signals
+			// were set up.
+			env := map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL}
+			getenv := func(key string) string {
+				if key == tt.cancelAt {
+					cancel()
+				}
+				return env[key]
+			}
+			cleanup, err := setup(ctx, "checkout", getenv)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("setup = %v, want context.Canceled", err)
+			}
+			if cleanup == nil {
+				t.Fatal("setup returned a nil cleanup")
+			}
+
+			tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+			if !ok {
+				t.Fatal("tracer provider not installed")
+			}
+			if _, span := tp.Tracer("test").Start(context.Background(), "after cancel"); span.IsRecording() {
+				t.Error("tracer provider still records spans, want it shut down")
+			}
+
+			mp, ok := otel.GetMeterProvider().(*sdkmetric.MeterProvider)
+			if !ok {
+				t.Fatal("meter provider not installed")
+			}
+			if _, noop := mp.Meter("test").(metricnoop.Meter); !noop {
+				t.Error("meter provider still hands out meters, want it shut down")
+			}
+
+			lp, ok := global.GetLoggerProvider().(*sdklog.LoggerProvider)
+			if ok != tt.wantLogger {
+				t.Fatalf("logger provider installed = %v, want %v", ok, tt.wantLogger)
+			}
+			if ok {
+				if _, noop := lp.Logger("test").(lognoop.Logger); !noop {
+					t.Error("logger provider still hands out loggers, want it shut down")
+				}
+			}
+		})
+	}
+}
+
+// recordingLogProcessor keeps the records emitted to it.
+type recordingLogProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *recordingLogProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, r.Clone())
+	return nil
+}
+
+func (p *recordingLogProcessor) Shutdown(context.Context) error   { return nil }
+func (p *recordingLogProcessor) ForceFlush(context.Context) error { return nil }
+
+func TestLogRecordTraceFlags(t *testing.T) {
+	rec := &recordingLogProcessor{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(rec))
+	prev := global.GetLoggerProvider()
+	global.SetLoggerProvider(lp)
+	t.Cleanup(func() { global.SetLoggerProvider(prev) })
+	logger := slog.New(newLogHandler(newConfig(), "checkout"))
+
+	tests := []struct {
+		name  string
+		flags trace.TraceFlags
+	}{
+		{"sampled", trace.FlagsSampled},
+		{"unsampled", 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+				SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, byte(i)},
+				TraceFlags: tt.flags,
+			})
+			logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "order placed")
+
+			rec.mu.Lock()
+			r := rec.records[len(rec.records)-1]
+			rec.mu.Unlock()
+			if r.TraceID() != sc.TraceID() || r.SpanID() != sc.SpanID() {
+				t.Errorf("record IDs = %s/%s, want %s/%s", r.TraceID(), r.SpanID(), sc.TraceID(), sc.SpanID())
+			}
+			if r.TraceFlags() != tt.flags {
+				t.Errorf("record trace flags = %s, want %s", r.TraceFlags(), tt.flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// WithProcessMetrics reports two process metrics, a lightweight
+// alternative to full runtime instrumentation:
+//
+//   - process.cpu.time (s), CPU time consumed, split by cpu.mode
+//     ("user", "system"); not reported on platforms without getrusage;
+//   - process.memory.usage (By), the resident set size from /proc on Linux,
+//     or the memory mapped by the Go runtime elsewhere.
+func WithProcessMetrics() Option {
+	return func(c *config) {
+		c.processMetrics = true
+	}
+}
+
+// registerProcessMetrics registers the WithProcessMetrics instruments on the
+// global meter.
+func registerProcessMetrics() error {
+	meter := otel.Meter(selfScopeName)
+	cpuTime, err := meter.Float64ObservableCounter("process.cpu.time",
+		metric.WithDescription("Total CPU seconds broken down by mode."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+	memory, err := meter.Int64ObservableUpDownCounter("process.memory.usage",
+		metric.WithDescription("The amount of physical memory in use."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+
+	user := metric.WithAttributes(attribute.String("cpu.mode", "user"))
+	system := metric.WithAttributes(attribute.String("cpu.mode", "system"))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if u, s, ok := cpuTimes(); ok {
+			o.ObserveFloat64(cpuTime, u, user)
+			o.ObserveFloat64(cpuTime, s, system)
+		}
+		o.ObserveInt64(memory, memoryUsage())
+		return nil
+	}, cpuTime, memory)
+	return err
+}
+
+// memoryUsage returns the resident set size from /proc/self/statm, falling
+// back to the memory the Go runtime has mapped from the OS and not released.
+func memoryUsage() int64 {
+	if statm, err := os.ReadFile("/proc/self/statm"); err == nil {
+		if fields := strings.Fields(string(statm)); len(fields) > 1 {
+			if pages, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+				return pages * int64(os.Getpagesize())
+			}
+		}
+	}
+
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
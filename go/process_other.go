@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// cpuTimes is not supported without getrusage.
+func cpuTimes() (user, system float64, ok bool) {
+	return 0, 0, false
+}
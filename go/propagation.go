@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// WithB3Propagator additionally extracts trace context from B3 headers
+// (single and multi-header). Outgoing requests still only carry W3C headers.
+func WithB3Propagator() Option {
+	return func(c *config) {
+		c.b3Propagator = true
+	}
+}
+
+// WithJaegerPropagator additionally extracts trace context from the Jaeger
+// uber-trace-id header. Outgoing requests still only carry W3C headers.
+func WithJaegerPropagator() Option {
+	return func(c *config) {
+		c.jaegerPropagator = true
+	}
+}
+
+// newPropagator builds the composite propagator installed globally.
+// Legacy formats come first so that, when an incoming request carries
+// several formats, the W3C traceparent header wins.
+func newPropagator(cfg *config) propagation.TextMapPropagator {
+	var props []propagation.TextMapPropagator
+	if cfg.b3Propagator {
+		props = append(props, extractOnly{b3.New()})
+	}
+	if cfg.jaegerPropagator {
+		props = append(props, extractOnly{jaeger.Jaeger{}})
+	}
+	props = append(props, propagation.TraceContext{}, propagation.Baggage{})
+	return propagation.NewCompositeTextMapPropagator(props...)
+}
+
+// extractOnly wraps a propagator so it only reads incoming context.
+type extractOnly struct {
+	propagation.TextMapPropagator
+}
+
+func (extractOnly) Inject(context.Context, propagation.TextMapCarrier) {}
+
+func (extractOnly) Fields() []string { return nil }
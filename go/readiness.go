@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// firstExport is closed by markExported once any export has succeeded.
+var (
+	firstExport     = make(chan struct{})
+	firstExportOnce sync.Once
+)
+
+// WaitForFirstExport blocks until an export of any signal has succeeded, or
+// ctx is done, in which case it returns ctx.Err(). Orchestration can use it
+// to report the process ready only once telemetry actually reaches the
+// collector, rather than when it is merely configured:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+//	defer cancel()
+//	if err := WaitForFirstExport(ctx); err != nil { ... }
+//
+// An OTLP export counts as successful when the collector answers with a 2xx
+// status, including partial successes. Console exporters write locally, so
+// any of them being set up counts as success right away.
+//
+// Only the first success is tracked: once it returned nil, it keeps
+// returning nil immediately, even if later exports fail or the providers
+// are shut down. Without a deadline on ctx it waits indefinitely, e.g. when
+// all signals are disabled.
+func WaitForFirstExport(ctx context.Context) error {
+	select {
+	case <-firstExport:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// markExported records that an export succeeded.
+func markExported() {
+	firstExportOnce.Do(func() { close(firstExport) })
+}
+
+// firstExportTransport calls markExported on the first 2xx response.
+type firstExportTransport struct {
+	base http.RoundTripper
+}
+
+func (t firstExportTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode/100 == 2 {
+		markExported()
+	}
+	return resp, err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultRedactedQueryKeys are the query parameters masked by
+// WithURLRedaction when no keys are given.
+var DefaultRedactedQueryKeys = []string{"token", "access_token", "key", "api_key", "apikey", "password", "secret", "signature"}
+
+// redactedValue replaces the value of a redacted query parameter.
+const redactedValue = "REDACTED"
+
+// WithURLRedaction masks the values of the given query parameters in the URL
+// recorded on spans (url.full, and http.target or http.url with the old
+// semantic conventions). Keys are matched case-insensitively. Without keys,
+// DefaultRedactedQueryKeys is used. The handler or transport still sees the
+// original URL.
+func WithURLRedaction(keys ...string) HTTPOption {
+	if len(keys) == 0 {
+		keys = DefaultRedactedQueryKeys
+	}
+	return func(c *httpConfig) {
+		c.redactKeys = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			c.redactKeys[strings.ToLower(k)] = true
+		}
+	}
+}
+
+// originalURLKey is the context key under which the unredacted request URL is
+// kept while otelhttp sees the redacted one.
+type originalURLKey struct{}
+
+// redactRequest returns a shallow copy of r whose URL has the query values
+// of keys masked, with the original URL stored in its context. It returns r
+// unchanged if nothing needs redacting.
+func redactRequest(r *http.Request, keys map[string]bool) *http.Request {
+	if r.URL == nil || r.URL.RawQuery == "" {
+		return r
+	}
+	query, ok := redactQuery(r.URL.RawQuery, keys)
+	if !ok {
+		return r
+	}
+	redacted := *r.URL
+	redacted.RawQuery = query
+	r = r.WithContext(context.WithValue(r.Context(), originalURLKey{}, r.URL))
+	r.URL = &redacted
+	return r
+}
+
+// restoreRequest undoes redactRequest on the request handed on by otelhttp.
+func restoreRequest(r *http.Request) *http.Request {
+	u, ok := r.Context().Value(originalURLKey{}).(*url.URL)
+	if !ok || r.URL == u {
+		return r
+	}
+	r = r.WithContext(r.Context())
+	r.URL = u
+	return r
+}
+
+// redactQuery masks the values of keys in rawQuery, keeping the order and
+// encoding of the other parameters. It reports whether anything was masked.
+func redactQuery(rawQuery string, keys map[string]bool) (string, bool) {
+	params := strings.Split(rawQuery, "&")
+	changed := false
+	for i, p := range params {
+		name, _, _ := strings.Cut(p, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if keys[strings.ToLower(name)] {
+			params[i], _, _ = strings.Cut(p, "=")
+			params[i] += "=" + redactedValue
+			changed = true
+		}
+	}
+	return strings.Join(params, "&"), changed
+}
+
+// redactionHandler hides the query values matched by keys from the otelhttp
+// handler next, whose inner handler h gets the original request back.
+func redactionHandler(next http.Handler, keys map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, redactRequest(r, keys))
+	})
+}
+
+// restoreHandler passes the unredacted request to h.
+func restoreHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, restoreRequest(r))
+	})
+}
+
+// restoreTransport sends the unredacted request through base.
+type restoreTransport struct {
+	base http.RoundTripper
+}
+
+func (t restoreTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(restoreRequest(req))
+}
+
+// redactionTransport hides the query values matched by keys from the
+// otelhttp transport next.
+type redactionTransport struct {
+	next http.RoundTripper
+	keys map[string]bool
+}
+
+func (t redactionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(redactRequest(req, t.keys))
+}
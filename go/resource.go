@@ -0,0 +1,586 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Resource attribute keys set by this setup. They are spelled out rather
+// than taken from the semconv package so that upgrading semconv never
+// renames attributes that downstream dashboards depend on. Reference these
+// instead of hardcoding the strings.
+const (
+	ServiceNameKey    = attribute.Key("service.name")
+	ServiceVersionKey = attribute.Key("service.version")
+	VCSRevisionKey    = attribute.Key("vcs.revision")
+	// DeploymentColorKey has no semantic convention; it is specific to
+	// blue/green deployments using this setup.
+	DeploymentColorKey = attribute.Key("deployment.color")
+	// DeployIDKey identifies a single release, e.g. a CI deploy id.
+	DeployIDKey = attribute.Key("deploy.id")
+	// CloudRegionKey is also set by cloud resource detectors.
+	CloudRegionKey = attribute.Key("cloud.region")
+
+	// Set by WithBuildInfo.
+	ProcessRuntimeNameKey        = attribute.Key("process.runtime.name")
+	ProcessRuntimeVersionKey     = attribute.Key("process.runtime.version")
+	ProcessRuntimeDescriptionKey = attribute.Key("process.runtime.description")
+	// There is no semantic convention for the main module.
+	GoModulePathKey    = attribute.Key("go.module.path")
+	GoModuleVersionKey = attribute.Key("go.module.version")
+)
+
+// DefaultSchemaURL is the semantic conventions schema the resource
+// attributes above follow (semconv v1.21.0).
+const DefaultSchemaURL = semconv.SchemaURL
+
+// WithSchemaURL overrides the semantic conventions schema URL recorded on the
+// resource. The attribute keys themselves are not changed.
+func WithSchemaURL(schemaURL string) Option {
+	return func(c *config) {
+		c.schemaURL = schemaURL
+	}
+}
+
+// WithResourceKeyRewrite renames resource attribute keys, e.g. to strip a
+// platform-specific prefix. rewrite is called for every key after all
+// detectors have run, including attributes from OTEL_RESOURCE_ATTRIBUTES.
+// If two keys are rewritten to the same name, the one sorting last wins.
+func WithResourceKeyRewrite(rewrite func(string) string) Option {
+	return func(c *config) {
+		c.resourceKeyRewrite = rewrite
+	}
+}
+
+// WithResourceTransform post-processes the resource before the providers are
+// built, e.g. to lowercase values or add attributes computed from others.
+// transform is called last, after detectors, OTEL_RESOURCE_ATTRIBUTES and
+// the attributes set by this setup are merged and after
+// WithExcludeResourceAttributes and WithResourceKeyRewrite have been
+// applied, so it sees the resource as it would otherwise be exported;
+// several transforms run in the order given. The per-signal Observe target
+// package attribute is only added afterwards. An error or a nil resource
+// from transform fails setup.
+func WithResourceTransform(transform func(*resource.Resource) (*resource.Resource, error)) Option {
+	return func(c *config) {
+		c.resourceTransforms = append(c.resourceTransforms, transform)
+	}
+}
+
+// WithExcludeResourceAttributes removes the resource attributes with the
+// given keys once all detectors have run, e.g. to keep an internal host.name
+// from leaving the network while still using the host detector. Attributes
+// set by this setup and from OTEL_RESOURCE_ATTRIBUTES are removed as well.
+// Keys are matched before WithResourceKeyRewrite renames them. The console
+// exporters print the resource as the SDK holds it, which still includes
+// excluded OTEL_RESOURCE_ATTRIBUTES keys.
+func WithExcludeResourceAttributes(keys ...string) Option {
+	return func(c *config) {
+		if c.excludedResourceKeys == nil {
+			c.excludedResourceKeys = map[attribute.Key]bool{}
+		}
+		for _, k := range keys {
+			c.excludedResourceKeys[attribute.Key(k)] = true
+		}
+	}
+}
+
+// ResourceMergePriority decides which source wins when resource detectors
+// and the attributes set by this setup provide the same key.
+type ResourceMergePriority int
+
+const (
+	// ResourceExplicitWins keeps the attributes set by this setup
+	// (service.name, service.version, vcs.revision) over detected ones. This
+	// is the default.
+	ResourceExplicitWins ResourceMergePriority = iota
+	// ResourceDetectorsWin lets detectors, including OTEL_RESOURCE_ATTRIBUTES
+	// and OTEL_SERVICE_NAME, override the attributes set by this setup.
+	ResourceDetectorsWin
+)
+
+// WithResourceMergePriority sets which source prevails for resource keys set
+// both by detectors and by this setup. Detectors added with options are
+// applied in order among themselves, then the environment, so
+// OTEL_RESOURCE_ATTRIBUTES always beats the other detectors.
+func WithResourceMergePriority(p ResourceMergePriority) Option {
+	return func(c *config) {
+		c.resourceMergePriority = p
+	}
+}
+
+// WithGitCommit sets the vcs.revision resource attribute. Without it the
+// VCS_REVISION or GIT_COMMIT environment variables are used, falling back to
+// the revision the Go toolchain stamps into the binary.
+func WithGitCommit(commit string) Option {
+	return func(c *config) {
+		c.gitCommit = commit
+	}
+}
+
+// resolveGitCommit returns the commit to record as vcs.revision, in order of
+// precedence: WithGitCommit, VCS_REVISION, GIT_COMMIT, build info.
+func resolveGitCommit(cfg *config) string {
+	for _, commit := range []string{cfg.gitCommit, cfg.getenv("VCS_REVISION"), cfg.getenv("GIT_COMMIT")} {
+		if commit != "" {
+			return commit
+		}
+	}
+	return buildVCSRevision()
+}
+
+// WithDeploymentColor sets the deployment.color resource attribute, e.g.
+// "blue" or "green", so telemetry from both sides of a blue/green rollout can
+// be told apart. Without it the DEPLOYMENT_COLOR environment variable is
+// used; if neither is set the attribute is omitted.
+func WithDeploymentColor(color string) Option {
+	return func(c *config) {
+		c.deploymentColor = color
+	}
+}
+
+// WithDeployID sets the deploy.id resource attribute, e.g. to the id CI
+// assigns to a release, to isolate the telemetry of one deploy. Without it
+// the DEPLOY_ID environment variable is used; if neither is set the
+// attribute is omitted.
+func WithDeployID(id string) Option {
+	return func(c *config) {
+		c.deployID = id
+	}
+}
+
+// WithRegion sets the cloud.region resource attribute, e.g. "eu-west-1" or
+// the name of an on-premises region, for grouping telemetry by geography.
+// Without it the CLOUD_REGION, then the REGION environment variable is
+// used; if none is set the attribute is omitted. The value overrides a
+// region found by a cloud detector unless ResourceDetectorsWin is selected
+// with WithResourceMergePriority; cloud.region in OTEL_RESOURCE_ATTRIBUTES
+// follows the same rule.
+func WithRegion(region string) Option {
+	return func(c *config) {
+		c.region = region
+	}
+}
+
+// WithBuildInfo adds resource attributes describing the binary, read from
+// runtime/debug.ReadBuildInfo: process.runtime.name ("go", or the compiler
+// name for other compilers), process.runtime.version (the Go version that
+// built it), process.runtime.description, and go.module.path and
+// go.module.version for the main module. The version is omitted for
+// development builds. Binaries built without module support carry no build
+// info; the attributes are then omitted.
+func WithBuildInfo() Option {
+	return func(c *config) {
+		c.buildInfo = true
+	}
+}
+
+// buildInfoAttributes returns the WithBuildInfo attributes, or nil without
+// build info.
+func buildInfoAttributes() []attribute.KeyValue {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	name := runtime.Compiler
+	if name == "gc" {
+		name = "go"
+	}
+	attrs := []attribute.KeyValue{
+		ProcessRuntimeNameKey.String(name),
+		ProcessRuntimeVersionKey.String(info.GoVersion),
+		ProcessRuntimeDescriptionKey.String("go version " + info.GoVersion + " " + runtime.GOOS + "/" + runtime.GOARCH),
+		GoModulePathKey.String(info.Main.Path),
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		attrs = append(attrs, GoModuleVersionKey.String(v))
+	}
+	return attrs
+}
+
+// BuildResource creates the resource setupInstrumentation would use for
+// serviceName and opts, so it can be shared with other SDKs in the process.
+func BuildResource(serviceName string, opts ...Option) (*resource.Resource, error) {
+	cfg, err := resolveConfig(os.Getenv, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return buildResource(context.Background(), cfg, serviceName)
+}
+
+// GetResource returns the resource shared by all signals.
+// Call setupInstrumentation first.
+func GetResource() *resource.Resource {
+	return appResource
+}
+
+// EncodeResourceAttributes serializes the attributes of res in the
+// OTEL_RESOURCE_ATTRIBUTES format: comma-separated key=value pairs sorted by
+// key, with values percent-encoded. Any OpenTelemetry SDK reading that
+// variable reconstructs the same attributes, as strings. Keys are not
+// decoded by the SDKs, so it fails if a key contains ',', '=' or '%', or
+// would lose surrounding whitespace.
+func EncodeResourceAttributes(res *resource.Resource) (string, error) {
+	pairs := make([]string, 0, res.Len())
+	var invalid []string
+	for _, kv := range res.Attributes() {
+		key := string(kv.Key)
+		if strings.ContainsAny(key, ",=%") || strings.TrimSpace(key) != key {
+			invalid = append(invalid, strconv.Quote(key))
+			continue
+		}
+		pairs = append(pairs, key+"="+url.PathEscape(kv.Value.Emit()))
+	}
+	if len(invalid) > 0 {
+		return "", fmt.Errorf("resource attribute keys cannot be encoded in OTEL_RESOURCE_ATTRIBUTES: %s", strings.Join(invalid, ", "))
+	}
+	return strings.Join(pairs, ","), nil
+}
+
+// buildResource creates the resource shared by all signals.
+func buildResource(ctx context.Context, cfg *config, serviceName string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		ServiceNameKey.String(serviceName),
+		ServiceVersionKey.String("1.0.0"),
+	}
+	if commit := cfg.gitCommit; commit != "" {
+		attrs = append(attrs, VCSRevisionKey.String(commit))
+	}
+	if color := cfg.deploymentColor; color != "" {
+		attrs = append(attrs, DeploymentColorKey.String(color))
+	}
+	if id := cfg.deployID; id != "" {
+		attrs = append(attrs, DeployIDKey.String(id))
+	}
+	if region := cfg.region; region != "" {
+		attrs = append(attrs, CloudRegionKey.String(region))
+	}
+	if cfg.buildInfo {
+		if build := buildInfoAttributes(); build != nil {
+			attrs = append(attrs, build...)
+		} else {
+			cfg.logger.Debug("build info unavailable, omitting build attributes")
+		}
+	}
+	// Attributes from flags come last so they override the defaults above
+	attrs = append(attrs, cfg.resourceAttributes...)
+
+	// resource.New merges its options in order, later ones overriding earlier
+	// ones for the same key.
+	detected := []resource.Option{
+		resource.WithDetectors(withDetectorTimeout(cfg)...),
+		resource.WithFromEnv(),
+	}
+	opts := []resource.Option{resource.WithSchemaURL(cfg.schemaURL)}
+	if cfg.resourceMergePriority == ResourceDetectorsWin {
+		opts = append(opts, resource.WithAttributes(attrs...))
+		opts = append(opts, detected...)
+	} else {
+		opts = append(opts, detected...)
+		opts = append(opts, resource.WithAttributes(attrs...))
+	}
+
+	res, err := resource.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.excludedResourceKeys) > 0 {
+		res = excludeResourceKeys(res, cfg.excludedResourceKeys)
+	}
+	if cfg.resourceKeyRewrite != nil {
+		res = rewriteResourceKeys(res, cfg.resourceKeyRewrite)
+	}
+	for _, transform := range cfg.resourceTransforms {
+		if res, err = transform(res); err != nil {
+			return nil, fmt.Errorf("resource transform: %w", err)
+		}
+		if res == nil {
+			return nil, errors.New("resource transform returned a nil resource")
+		}
+	}
+	return res, nil
+}
+
+// excludeResourceKeys returns a copy of res without the attributes whose key
+// is in excluded.
+func excludeResourceKeys(res *resource.Resource, excluded map[attribute.Key]bool) *resource.Resource {
+	attrs := slices.DeleteFunc(res.Attributes(), func(kv attribute.KeyValue) bool {
+		return excluded[kv.Key]
+	})
+	return resource.NewWithAttributes(res.SchemaURL(), attrs...)
+}
+
+// droppedEnvResourceKeys returns the keys of OTEL_RESOURCE_ATTRIBUTES missing
+// from res, removed by WithExcludeResourceAttributes, WithResourceKeyRewrite
+// or a transform. The SDK providers merge the environment into the resource
+// they are given, adding these keys back, so resourceFilterTransport strips
+// them from the export requests.
+func droppedEnvResourceKeys(res *resource.Resource) map[string]bool {
+	var dropped map[string]bool
+	set := res.Set()
+	for _, kv := range resource.Environment().Attributes() {
+		if set.HasValue(kv.Key) {
+			continue
+		}
+		if dropped == nil {
+			dropped = map[string]bool{}
+		}
+		dropped[string(kv.Key)] = true
+	}
+	return dropped
+}
+
+// resourceFilterTransport removes the resource attributes with the given keys
+// from OTLP export requests. A body it cannot decode is sent unchanged.
+type resourceFilterTransport struct {
+	base   http.RoundTripper
+	signal string
+	keys   map[string]bool
+}
+
+func (t resourceFilterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, r, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return t.base.RoundTrip(r)
+	}
+	if filtered, err := t.filter(body, r.Header.Get("Content-Encoding")); err == nil {
+		r.Body = io.NopCloser(bytes.NewReader(filtered))
+		r.ContentLength = int64(len(filtered))
+		r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(filtered)), nil }
+	}
+	return t.base.RoundTrip(r)
+}
+
+// filter returns body without the filtered resource attributes, encoded as
+// it was received.
+func (t resourceFilterTransport) filter(body []byte, encoding string) ([]byte, error) {
+	raw, err := decodeBody(body, encoding)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		msg       proto.Message
+		resources []*resourcepb.Resource
+	)
+	switch t.signal {
+	case signalTraces:
+		req := &coltracepb.ExportTraceServiceRequest{}
+		err = proto.Unmarshal(raw, req)
+		for _, rs := range req.GetResourceSpans() {
+			resources = append(resources, rs.GetResource())
+		}
+		msg = req
+	case signalMetrics:
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		err = proto.Unmarshal(raw, req)
+		for _, rm := range req.GetResourceMetrics() {
+			resources = append(resources, rm.GetResource())
+		}
+		msg = req
+	case signalLogs:
+		req := &collogspb.ExportLogsServiceRequest{}
+		err = proto.Unmarshal(raw, req)
+		for _, rl := range req.GetResourceLogs() {
+			resources = append(resources, rl.GetResource())
+		}
+		msg = req
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range resources {
+		if res != nil {
+			res.Attributes = slices.DeleteFunc(res.Attributes, func(kv *commonpb.KeyValue) bool {
+				return t.keys[kv.GetKey()]
+			})
+		}
+	}
+
+	out, err := proto.Marshal(msg)
+	if err != nil || encoding != "gzip" {
+		return out, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(out); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rewriteResourceKeys returns a copy of res with every key passed through
+// rewrite.
+func rewriteResourceKeys(res *resource.Resource, rewrite func(string) string) *resource.Resource {
+	attrs := res.Attributes()
+	for i, kv := range attrs {
+		attrs[i] = attribute.KeyValue{Key: attribute.Key(rewrite(string(kv.Key))), Value: kv.Value}
+	}
+	return resource.NewWithAttributes(res.SchemaURL(), attrs...)
+}
+
+// WithResourceAttributesOnSpans copies the resource attributes with the
+// given keys onto every span as span attributes, for backends or views that
+// do not show resource attributes on individual spans. Keys the resource
+// does not have are ignored. Each copied key is repeated on every span, so
+// list only the few that are needed, e.g. "service.name".
+func WithResourceAttributesOnSpans(keys ...string) Option {
+	return func(c *config) {
+		for _, k := range keys {
+			c.resourceSpanKeys = append(c.resourceSpanKeys, attribute.Key(k))
+		}
+	}
+}
+
+// resourceAttributesSpanProcessor sets a fixed set of resource attributes on
+// spans on start.
+type resourceAttributesSpanProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+func newResourceAttributesSpanProcessor(res *resource.Resource, keys []attribute.Key) resourceAttributesSpanProcessor {
+	return resourceAttributesSpanProcessor{attrs: resourceAttributes(res, keys)}
+}
+
+// resourceAttributes returns the attributes of res with the given keys, in
+// the order of keys. Keys res does not have are skipped.
+func resourceAttributes(res *resource.Resource, keys []attribute.Key) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	set := res.Set()
+	for _, k := range keys {
+		if v, ok := set.Value(k); ok {
+			attrs = append(attrs, attribute.KeyValue{Key: k, Value: v})
+		}
+	}
+	return attrs
+}
+
+func (p resourceAttributesSpanProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+func (resourceAttributesSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (resourceAttributesSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (resourceAttributesSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// WithResourceAttributesOnMetrics copies the resource attributes with the
+// given keys onto every exported metric data point, for Observe queries
+// that filter or group metrics by them, e.g. "deployment.environment". Keys
+// the resource does not have are ignored; an attribute recorded on the data
+// point itself keeps its value.
+//
+// Every copied attribute becomes part of each time series' identity. Keys
+// that are constant for a service, such as service.name, are cheap, but
+// per-instance ones such as service.instance.id, host.name or k8s.pod.name
+// create a new series for every instance and restart, multiplying the
+// series count in the backend. They also add to every data point's size.
+func WithResourceAttributesOnMetrics(keys ...string) Option {
+	return func(c *config) {
+		for _, k := range keys {
+			c.resourceMetricKeys = append(c.resourceMetricKeys, attribute.Key(k))
+		}
+	}
+}
+
+// resourceAttributesExporter adds a fixed set of resource attributes to the
+// data points it exports.
+type resourceAttributesExporter struct {
+	sdkmetric.Exporter
+	attrs []attribute.KeyValue
+}
+
+func newResourceAttributesExporter(exporter sdkmetric.Exporter, res *resource.Resource, keys []attribute.Key) sdkmetric.Exporter {
+	attrs := resourceAttributes(res, keys)
+	if len(attrs) == 0 {
+		return exporter
+	}
+	return resourceAttributesExporter{Exporter: exporter, attrs: attrs}
+}
+
+// Export sets the attributes on rm in place. The SDK assigns every data
+// point's attributes anew on each collection, so they do not accumulate.
+func (e resourceAttributesExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for i := range rm.ScopeMetrics {
+		metrics := rm.ScopeMetrics[i].Metrics
+		for j := range metrics {
+			switch data := metrics[j].Data.(type) {
+			case metricdata.Gauge[int64]:
+				extendAttributes(data.DataPoints, dataPointAttrs[int64], e.attrs)
+			case metricdata.Gauge[float64]:
+				extendAttributes(data.DataPoints, dataPointAttrs[float64], e.attrs)
+			case metricdata.Sum[int64]:
+				extendAttributes(data.DataPoints, dataPointAttrs[int64], e.attrs)
+			case metricdata.Sum[float64]:
+				extendAttributes(data.DataPoints, dataPointAttrs[float64], e.attrs)
+			case metricdata.Histogram[int64]:
+				extendAttributes(data.DataPoints, histogramAttrs[int64], e.attrs)
+			case metricdata.Histogram[float64]:
+				extendAttributes(data.DataPoints, histogramAttrs[float64], e.attrs)
+			case metricdata.ExponentialHistogram[int64]:
+				extendAttributes(data.DataPoints, expHistogramAttrs[int64], e.attrs)
+			case metricdata.ExponentialHistogram[float64]:
+				extendAttributes(data.DataPoints, expHistogramAttrs[float64], e.attrs)
+			case metricdata.Summary:
+				extendAttributes(data.DataPoints, summaryAttrs, e.attrs)
+			}
+		}
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+// extendAttributes adds extra to the attribute set of every point, keeping
+// the point's own value for keys it already has.
+func extendAttributes[P any](points []P, attrs func(*P) *attribute.Set, extra []attribute.KeyValue) {
+	for i := range points {
+		set := attrs(&points[i])
+		// NewSet keeps the last value for duplicate keys.
+		*set = attribute.NewSet(append(slices.Clip(extra), set.ToSlice()...)...)
+	}
+}
+
+func dataPointAttrs[N int64 | float64](p *metricdata.DataPoint[N]) *attribute.Set {
+	return &p.Attributes
+}
+
+func histogramAttrs[N int64 | float64](p *metricdata.HistogramDataPoint[N]) *attribute.Set {
+	return &p.Attributes
+}
+
+func expHistogramAttrs[N int64 | float64](p *metricdata.ExponentialHistogramDataPoint[N]) *attribute.Set {
+	return &p.Attributes
+}
+
+func summaryAttrs(p *metricdata.SummaryDataPoint) *attribute.Set { return &p.Attributes }
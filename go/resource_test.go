@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestBuildResourceMergePriority(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=payments")
+	t.Setenv("OTEL_SERVICE_NAME", "")
+
+	tests := []struct {
+		priority ResourceMergePriority
+		want     map[attribute.Key]string
+	}{
+		{ResourceExplicitWins, map[attribute.Key]string{
+			"service.version": "1.0.0",
+			"vcs.revision":    "4f2c9a1",
+			"host.name":       "detected-host",
+			"team":            "payments",
+		}},
+		{ResourceDetectorsWin, map[attribute.Key]string{
+			"service.version": "2.3.4",
+			"vcs.revision":    "d41d8cd",
+			"host.name":       "detected-host",
+			"team":            "payments",
+		}},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, nil,
+			WithGitCommit("4f2c9a1"),
+			WithResourceMergePriority(tt.priority),
+		)
+		cfg.detectors = []resource.Detector{
+			resource.StringDetector("", "service.version", func() (string, error) { return "2.3.4", nil }),
+			resource.StringDetector("", "vcs.revision", func() (string, error) { return "d41d8cd", nil }),
+			resource.StringDetector("", "host.name", func() (string, error) { return "detected-host", nil }),
+		}
+		res, err := buildResource(context.Background(), cfg, "checkout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		set := res.Set()
+		for key, want := range tt.want {
+			if got, _ := set.Value(key); got.AsString() != want {
+				t.Errorf("priority %d: %s = %q, want %q", tt.priority, key, got.AsString(), want)
+			}
+		}
+		if got, _ := set.Value(ServiceNameKey); got.AsString() != "checkout" {
+			t.Errorf("priority %d: service.name = %q, want checkout", tt.priority, got.AsString())
+		}
+	}
+}
+
+func TestBuildResourceEnvBeatsDetectors(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "cloud.region=ap-south-1")
+	cfg := testConfig(t, nil, WithResourceMergePriority(ResourceDetectorsWin))
+	cfg.detectors = []resource.Detector{
+		resource.StringDetector("", "cloud.region", func() (string, error) { return "us-east-1", nil }),
+	}
+	res, err := buildResource(context.Background(), cfg, "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := res.Set().Value(CloudRegionKey); got.AsString() != "ap-south-1" {
+		t.Errorf("cloud.region = %q, want the OTEL_RESOURCE_ATTRIBUTES value ap-south-1", got.AsString())
+	}
+}
+
+func TestRemovedResourceKeysNotExported(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		opts        []Option
+		absent      []string
+		present     []string
+	}{
+		{
+			name:    "excluded",
+			opts:    []Option{WithExcludeResourceAttributes("internal.zone", "service.version")},
+			absent:  []string{"internal.zone", "service.version"},
+			present: []string{"team", "service.name"},
+		},
+		{
+			name:        "excluded with gzip",
+			compression: "gzip",
+			opts:        []Option{WithExcludeResourceAttributes("internal.zone")},
+			absent:      []string{"internal.zone"},
+			present:     []string{"team", "service.name", "service.version"},
+		},
+		{
+			name:    "rewritten",
+			opts:    []Option{WithResourceKeyRewrite(func(k string) string { return strings.ReplaceAll(k, ".", "_") })},
+			absent:  []string{"internal.zone", "service.name"},
+			present: []string{"internal_zone", "service_name", "team"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "internal.zone=rack-12,team=payments")
+			t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", tt.compression)
+			collector := newTestCollector(t)
+			env := map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL}
+			cleanup, err := setup(context.Background(), "checkout", func(key string) string { return env[key] }, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, span := otel.Tracer("test").Start(context.Background(), "GET /checkout")
+			span.End()
+			counter, err := otel.Meter("test").Int64Counter("checkouts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			counter.Add(context.Background(), 1)
+			GetLogger().Info("checkout completed")
+			// Shutting down exports everything buffered
+			cleanup()
+
+			for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+				resources := collector.resourceKeys(signal)
+				if len(resources) == 0 {
+					t.Errorf("%s: nothing exported", signal)
+				}
+				for _, keys := range resources {
+					for _, k := range tt.absent {
+						if keys[k] {
+							t.Errorf("%s: exported resource has removed key %s", signal, k)
+						}
+					}
+					for _, k := range tt.present {
+						if !keys[k] {
+							t.Errorf("%s: exported resource %v lacks %s", signal, keys, k)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeResourceAttributesRoundTrip(t *testing.T) {
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "checkout"),
+		attribute.String("team", "payments, billing=yes 100%"),
+		attribute.Int("replicas", 3),
+	)
+	encoded, err := EncodeResourceAttributes(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", encoded)
+	decoded, err := resource.New(context.Background(), resource.WithFromEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[attribute.Key]string{
+		"service.name": "checkout",
+		"team":         "payments, billing=yes 100%",
+		"replicas":     "3",
+	}
+	if decoded.Len() != len(want) {
+		t.Errorf("decoded %d attributes from %q, want %d", decoded.Len(), encoded, len(want))
+	}
+	for key, v := range want {
+		if got, _ := decoded.Set().Value(key); got.AsString() != v {
+			t.Errorf("%s = %q, want %q", key, got.AsString(), v)
+		}
+	}
+}
+
+func TestEncodeResourceAttributesRejectsKeys(t *testing.T) {
+	for _, key := range []string{"a,b", "a=b", "a%2Cb", " padded"} {
+		res := resource.NewSchemaless(attribute.String(key, "v"), attribute.String("team", "payments"))
+		if encoded, err := EncodeResourceAttributes(res); err == nil {
+			t.Errorf("key %q encoded as %q, want an error", key, encoded)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithSetupRetry retries the setup of each signal up to attempts times in
+// total when it fails, waiting backoff before the second attempt and twice
+// as long before each further one. A signal's setup then also includes a
+// connectivity probe: an empty OTLP export request to its endpoint, which
+// fails if the collector cannot be reached or answers with 429 or a 5xx
+// status. This smooths over collectors that are not ready yet when the
+// service starts. A signal still failing after the last attempt is
+// disabled with its last error, as without retries.
+//
+// Signals are set up one after another, each with its own attempts. The
+// waits end early when the setup context ends, so use SetupContext with a
+// deadline to bound the total startup time; setup then fails with the
+// context's error. Console exporters are not probed.
+func WithSetupRetry(attempts int, backoff time.Duration) Option {
+	return func(c *config) {
+		c.setupAttempts = attempts
+		c.setupBackoff = backoff
+	}
+}
+
+// retrySetup calls create until it succeeds, it has been called
+// cfg.setupAttempts times, or ctx ends.
+func retrySetup[P any](ctx context.Context, cfg *config, signal string, create func() (P, error)) (P, error) {
+	backoff := cfg.setupBackoff
+	for attempt := 1; ; attempt++ {
+		p, err := create()
+		if err == nil || attempt >= cfg.setupAttempts || ctx.Err() != nil {
+			return p, err
+		}
+		cfg.logger.Warn("failed to setup signal, retrying", "signal", signal, "attempt", attempt, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return p, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// probeContextKey marks the requests of probeCollector, which are not
+// exports.
+type probeContextKey struct{}
+
+// probeCollector sends an empty export request with exp's probe client to
+// check that the collector is reachable and accepting requests.
+func probeCollector(ctx context.Context, exp exporterOptions) error {
+	u, err := url.Parse(exp.endpointURL)
+	if err != nil {
+		return err
+	}
+	u.Path = exp.urlPath
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, probeContextKey{}, true), http.MethodPost, u.String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range exp.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := exp.probeClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("collector probe: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return fmt.Errorf("collector probe: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeCollectorBypassesExportTransports(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	cfg := testConfig(t, map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL},
+		WithCircuitBreaker(1, time.Hour),
+		WithDebugExportLogging(),
+		WithInternalLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	exp := buildExporterOptions(cfg, signalTraces, cfg.otlpEndpoint, cfg.bearerToken)
+	if err := probeCollector(context.Background(), exp); err == nil {
+		t.Fatal("probe of an unavailable collector succeeded")
+	}
+
+	// A failed probe must not open the breaker for the exports.
+	req, err := http.NewRequest(http.MethodPost, exp.endpointURL+exp.urlPath, strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := exp.client.Do(req)
+	if err != nil {
+		t.Fatalf("export after a failed probe: %v", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 2 {
+		t.Errorf("collector got %d requests, want the probe and the export", got)
+	}
+	if got := strings.Count(logs.String(), "OTLP export"); got != 1 {
+		t.Errorf("logged %d export debug lines, want only the export's:\n%s", got, logs.String())
+	}
+}
+
+func TestRetrySetup(t *testing.T) {
+	cfg := newConfig(WithSetupRetry(3, time.Millisecond))
+	calls := 0
+	failTwice := func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errors.New("collector not ready")
+		}
+		return calls, nil
+	}
+	if got, err := retrySetup(context.Background(), cfg, signalTraces, failTwice); err != nil || got != 3 {
+		t.Errorf("retrySetup = %d, %v, want success on the third attempt", got, err)
+	}
+
+	calls = 0
+	cfg = newConfig(WithSetupRetry(2, time.Millisecond))
+	if _, err := retrySetup(context.Background(), cfg, signalTraces, failTwice); err == nil || calls != 2 {
+		t.Errorf("retrySetup made %d attempts with error %v, want 2 ending in the last error", calls, err)
+	}
+
+	// The wait ends with the context.
+	calls = 0
+	cfg = newConfig(WithSetupRetry(5, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := retrySetup(ctx, cfg, signalTraces, failTwice); !errors.Is(err, context.DeadlineExceeded) || calls != 1 {
+		t.Errorf("retrySetup made %d attempts with error %v, want 1 ending in the context's error", calls, err)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SamplingReasonKey is the span attribute recording which sampler rule kept
+// a span, when WithSamplingReason is enabled.
+const SamplingReasonKey = attribute.Key("sampling.reason")
+
+// WithSamplingReason records on every sampled span which sampler rule made
+// the decision, e.g. "parent", "always_on", "tracestate:edge" or
+// "rule:/checkout". This makes sampling auditable but adds an attribute to
+// every span, so it is opt-in.
+func WithSamplingReason() Option {
+	return func(c *config) {
+		c.samplingReason = true
+	}
+}
+
+// withReason adds the sampling reason attribute to a sampled result when
+// annotate is set.
+func withReason(r sdktrace.SamplingResult, annotate bool, reason string) sdktrace.SamplingResult {
+	if annotate && r.Decision == sdktrace.RecordAndSample {
+		r.Attributes = append(r.Attributes, SamplingReasonKey.String(reason))
+	}
+	return r
+}
+
+// samplingHint is a sampling decision attached to a context.
+type samplingHint struct {
+	decision sdktrace.SamplingDecision
+	reason   string
+}
+
+// samplingHintKey is the context key under which a sampling hint is stored
+// for the next entry span.
+type samplingHintKey struct{}
+
+// contextWithSamplingHint returns a copy of ctx carrying a sampling decision
+// that overrides the configured sampler for spans started from it that have
+// no local parent. reason is recorded by WithSamplingReason.
+func contextWithSamplingHint(ctx context.Context, decision sdktrace.SamplingDecision, reason string) context.Context {
+	return context.WithValue(ctx, samplingHintKey{}, samplingHint{decision: decision, reason: reason})
+}
+
+// hintSampler applies a sampling hint found in the parent context to entry
+// spans, i.e. spans without a local parent. Child spans are decided by the
+// wrapped sampler, which follows the parent and so inherits the hint's
+// decision.
+type hintSampler struct {
+	base     sdktrace.Sampler
+	annotate bool
+}
+
+func (s hintSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	if hint, ok := p.ParentContext.Value(samplingHintKey{}).(samplingHint); ok && (!psc.IsValid() || psc.IsRemote()) {
+		return withReason(sdktrace.SamplingResult{
+			Decision:   hint.decision,
+			Tracestate: psc.TraceState(),
+		}, s.annotate, hint.reason)
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s hintSampler) Description() string {
+	return "HintSampler{" + s.base.Description() + "}"
+}
+
+// SamplingPriorityTraceStateKey is the tracestate key under which
+// SetSamplingPriority records the sampling priority. Its value has the form
+// "p:<n>", with n a decimal integer, e.g. "observe=p:1".
+const SamplingPriorityTraceStateKey = "observe"
+
+// samplingPriorityPrefix precedes the priority in the tracestate value.
+const samplingPriorityPrefix = "p:"
+
+// samplingPriorityKey is the context key under which SetSamplingPriority
+// stores the priority for a trace not started yet.
+type samplingPriorityKey struct{}
+
+// SetSamplingPriority returns a copy of ctx that records a sampling
+// priority in the trace's tracestate, under SamplingPriorityTraceStateKey,
+// so this and downstream services that run this setup make the same
+// decision: a priority above 0 keeps the trace and 0 or below drops it,
+// overriding the parent's sampled flag and any other sampler.
+//
+// With a span in ctx, spans started from the returned context and requests
+// propagated from it carry the priority, while the span in ctx keeps its
+// own decision since it already started. Without a span, the priority
+// applies to the next trace started from the returned context. A priority
+// above 0 set on an unsampled span only takes effect across a process
+// boundary: local children stay unsampled, like the dropped span. Spans
+// whose sampling is driven by WithRouteSampling keep the route's decision.
+func SetSamplingPriority(ctx context.Context, priority int) context.Context {
+	span := trace.SpanFromContext(ctx)
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return context.WithValue(ctx, samplingPriorityKey{}, priority)
+	}
+	ts, err := sc.TraceState().Insert(SamplingPriorityTraceStateKey, formatSamplingPriority(priority))
+	if err != nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, prioritySpan{Span: span, sc: sc.WithTraceState(ts)})
+}
+
+// prioritySpan is a span whose span context carries an updated tracestate.
+// Everything else is forwarded to the span itself, so it stays the active
+// span of the context.
+type prioritySpan struct {
+	trace.Span
+	sc trace.SpanContext
+}
+
+func (s prioritySpan) SpanContext() trace.SpanContext { return s.sc }
+
+func formatSamplingPriority(priority int) string {
+	return samplingPriorityPrefix + strconv.Itoa(priority)
+}
+
+// samplingPriority returns the priority recorded in ts, if any.
+func samplingPriority(ts trace.TraceState) (int, bool) {
+	v, ok := strings.CutPrefix(ts.Get(SamplingPriorityTraceStateKey), samplingPriorityPrefix)
+	if !ok {
+		return 0, false
+	}
+	priority, err := strconv.Atoi(v)
+	return priority, err == nil
+}
+
+// prioritySampler applies the sampling priority from the tracestate of a
+// remote or sampled parent or, for new traces, from SetSamplingPriority, and
+// defers to base otherwise.
+type prioritySampler struct {
+	base     sdktrace.Sampler
+	annotate bool
+}
+
+func (s prioritySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	ts := psc.TraceState()
+	priority, ok := samplingPriority(ts)
+	if ok && !psc.IsRemote() && !psc.IsSampled() {
+		// A local parent that was not sampled: forcing its children would
+		// record fragments of a dropped trace.
+		ok = false
+	}
+	if !ok && !psc.IsValid() {
+		if priority, ok = p.ParentContext.Value(samplingPriorityKey{}).(int); ok {
+			// Record the priority on the new trace so it propagates.
+			var err error
+			if ts, err = ts.Insert(SamplingPriorityTraceStateKey, formatSamplingPriority(priority)); err != nil {
+				return s.base.ShouldSample(p)
+			}
+		}
+	}
+	if !ok {
+		return s.base.ShouldSample(p)
+	}
+	decision := sdktrace.Drop
+	if priority > 0 {
+		decision = sdktrace.RecordAndSample
+	}
+	return withReason(sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: ts,
+	}, s.annotate, "priority")
+}
+
+func (s prioritySampler) Description() string {
+	return "PrioritySampler{" + s.base.Description() + "}"
+}
+
+// WithTraceStateSampling forces sampling of spans whose parent carries the
+// vendor key in its W3C tracestate, e.g. "edge" for a tracestate of
+// "edge=p:1,rojo=00f067aa0ba902b7". Spans without the key are decided by the
+// default sampler. This lets an upstream proxy mark traces that must be kept.
+// The key only forces sampling across a process boundary: children of a local
+// parent that was not sampled stay unsampled, since local spans inherit the
+// tracestate and forcing them would record fragments of a dropped trace.
+func WithTraceStateSampling(key string) Option {
+	return func(c *config) {
+		c.traceStateSamplingKey = key
+	}
+}
+
+// traceStateSampler samples spans whose remote or sampled parent has key in
+// its tracestate and defers to base otherwise.
+type traceStateSampler struct {
+	key      string
+	base     sdktrace.Sampler
+	annotate bool
+}
+
+func (s traceStateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	parent := trace.SpanContextFromContext(p.ParentContext)
+	ts := parent.TraceState()
+	if ts.Get(s.key) != "" && (parent.IsRemote() || parent.IsSampled()) {
+		return withReason(sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: ts,
+		}, s.annotate, "tracestate:"+s.key)
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s traceStateSampler) Description() string {
+	return "TraceStateSampler{" + s.key + "," + s.base.Description() + "}"
+}
+
+// WithDropOrphanSpans drops spans with one of the given names when they have
+// no parent, neither local nor remote, instead of letting them start a new
+// trace. Use it for operations that should only ever run inside a trace,
+// such as background tasks that lose their context and would otherwise
+// produce noise as single-span traces. Spans with a parent are unaffected.
+//
+// Dropping is silent and includes legitimate entry points: a listed name
+// that also starts traces on purpose, e.g. a job run from a scheduler or a
+// request from a caller that does not propagate context, is never recorded
+// then, nor are its children.
+func WithDropOrphanSpans(names ...string) Option {
+	return func(c *config) {
+		if c.orphanSpanNames == nil {
+			c.orphanSpanNames = map[string]bool{}
+		}
+		for _, name := range names {
+			c.orphanSpanNames[name] = true
+		}
+	}
+}
+
+// orphanSampler drops root spans whose name is in names and defers to base
+// otherwise.
+type orphanSampler struct {
+	names map[string]bool
+	base  sdktrace.Sampler
+}
+
+func (s orphanSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if s.names[p.Name] && !trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s orphanSampler) Description() string {
+	return "OrphanSampler{" + s.base.Description() + "}"
+}
+
+// WithTenantSampling samples new traces at a ratio chosen by tenant, to
+// spend more of the trace budget on, say, premium tenants. The tenant is
+// the value of the baggage member key in the context a root span is
+// started with; ratios maps tenants to their ratio, and tenants missing
+// from it, or requests without the member, use base. Ratios are clamped
+// to [0, 1].
+//
+//	WithTenantSampling("tenant.id", map[string]float64{"acme": 1, "globex": 0.5}, 0.05)
+//
+// The member must be present when the trace starts: set it with
+// baggage.ContextWithBaggage before starting the root span, or have the
+// caller send it in the W3C baggage header, which the default propagators
+// extract. Spans with a parent follow the parent's decision whatever their
+// tenant, so services further down a trace need no configuration. Combined
+// with WithTargetSpanRate, the lower of the two probabilities applies.
+func WithTenantSampling(key string, ratios map[string]float64, base float64) Option {
+	return func(c *config) {
+		c.tenantSamplingKey = key
+		c.tenantRatios = maps.Clone(ratios)
+		c.tenantBaseRatio = base
+	}
+}
+
+// tenantSampler drops root spans outside their tenant's ratio and defers to
+// base for the rest and for spans with a parent. It uses the same trace ID
+// hash as the adaptive sampler, so below it the stricter ratio wins.
+type tenantSampler struct {
+	key       string
+	ratios    map[string]float64
+	baseRatio float64
+	base      sdktrace.Sampler
+}
+
+func (s tenantSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return s.base.ShouldSample(p)
+	}
+	ratio, ok := s.ratios[baggage.FromContext(p.ParentContext).Member(s.key).Value()]
+	if !ok {
+		ratio = s.baseRatio
+	}
+	if ratio < 1 && (ratio <= 0 || !sampledByRatio(p.TraceID, ratio)) {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s tenantSampler) Description() string {
+	return "TenantSampler{" + s.key + "," + s.base.Description() + "}"
+}
+
+// parentBasedSampler follows the parent's decision and uses root for spans
+// without a parent, tagging results with "parent" or rootReason.
+type parentBasedSampler struct {
+	sdktrace.Sampler
+	rootReason string
+	annotate   bool
+}
+
+func newParentBasedSampler(root sdktrace.Sampler, rootReason string, annotate bool) parentBasedSampler {
+	return parentBasedSampler{
+		Sampler:    sdktrace.ParentBased(root),
+		rootReason: rootReason,
+		annotate:   annotate,
+	}
+}
+
+func (s parentBasedSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	reason := s.rootReason
+	if trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		reason = "parent"
+	}
+	return withReason(s.Sampler.ShouldSample(p), s.annotate, reason)
+}
+
+// newSampler builds the sampler installed on the tracer provider.
+func newSampler(cfg *config) sdktrace.Sampler {
+	// Roots reaching the parent-based sampler passed the ratio of the
+	// rules or tenant sampler
+	rootReason := "always_on"
+	if cfg.samplingRules != nil {
+		rootReason = "rules"
+	} else if cfg.tenantSamplingKey != "" {
+		rootReason = "tenant"
+	}
+	var sampler sdktrace.Sampler = newParentBasedSampler(sdktrace.AlwaysSample(), rootReason, cfg.samplingReason)
+	if cfg.targetSpanRate > 0 {
+		sampler = newAdaptiveSampler(sampler, cfg.targetSpanRate, cfg.samplingReason)
+	}
+	if cfg.tenantSamplingKey != "" {
+		sampler = tenantSampler{key: cfg.tenantSamplingKey, ratios: cfg.tenantRatios, baseRatio: cfg.tenantBaseRatio, base: sampler}
+	}
+	if cfg.samplingRules != nil {
+		sampler = rulesSampler{rules: cfg.samplingRules, base: sampler}
+	}
+	if len(cfg.orphanSpanNames) > 0 {
+		sampler = orphanSampler{names: cfg.orphanSpanNames, base: sampler}
+	}
+	if cfg.traceStateSamplingKey != "" {
+		sampler = traceStateSampler{key: cfg.traceStateSamplingKey, base: sampler, annotate: cfg.samplingReason}
+	}
+	sampler = prioritySampler{base: sampler, annotate: cfg.samplingReason}
+	return hintSampler{base: sampler, annotate: cfg.samplingReason}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceStateSampler(t *testing.T) {
+	tests := []struct {
+		name       string
+		tracestate string
+		remote     bool
+		sampled    bool
+		want       sdktrace.SamplingDecision
+	}{
+		{"remote unsampled with key", "edge=p:1,rojo=00f067aa0ba902b7", true, false, sdktrace.RecordAndSample},
+		{"remote sampled with key", "edge=p:1", true, true, sdktrace.RecordAndSample},
+		{"key after other vendors", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE,edge=p:1", true, false, sdktrace.RecordAndSample},
+		{"remote unsampled without key", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", true, false, sdktrace.Drop},
+		{"key as a multi-tenant suffix only", "acme@edge=p:1", true, false, sdktrace.Drop},
+		{"empty tracestate", "", true, false, sdktrace.Drop},
+		{"local unsampled with key", "edge=p:1,rojo=00f067aa0ba902b7", false, false, sdktrace.Drop},
+		{"local sampled with key", "edge=p:1", false, true, sdktrace.RecordAndSample},
+	}
+	sampler := traceStateSampler{key: "edge", base: sdktrace.ParentBased(sdktrace.AlwaysSample())}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := trace.ParseTraceState(tt.tracestate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flags trace.TraceFlags
+			if tt.sampled {
+				flags = trace.FlagsSampled
+			}
+			parent := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+				SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+				TraceFlags: flags,
+				TraceState: ts,
+				Remote:     tt.remote,
+			})
+			res := sampler.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: trace.ContextWithSpanContext(context.Background(), parent),
+				TraceID:       parent.TraceID(),
+				Name:          "GET /checkout",
+				Kind:          trace.SpanKindServer,
+			})
+			if res.Decision != tt.want {
+				t.Errorf("decision = %v, want %v", res.Decision, tt.want)
+			}
+			if res.Decision == sdktrace.RecordAndSample && res.Tracestate.String() != ts.String() {
+				t.Errorf("tracestate = %q, want %q kept", res.Tracestate.String(), ts.String())
+			}
+		})
+	}
+}
+
+func TestTraceStateSamplerReason(t *testing.T) {
+	ts, err := trace.ParseTraceState("edge=p:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceState: ts,
+		Remote:     true,
+	})
+	sampler := traceStateSampler{key: "edge", base: sdktrace.NeverSample(), annotate: true}
+	res := sampler.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: trace.ContextWithSpanContext(context.Background(), parent),
+		TraceID:       parent.TraceID(),
+		Name:          "GET /checkout",
+	})
+	if len(res.Attributes) != 1 || res.Attributes[0] != SamplingReasonKey.String("tracestate:edge") {
+		t.Errorf("attributes = %v, want sampling.reason=tracestate:edge", res.Attributes)
+	}
+}
+
+func TestPrioritySampler(t *testing.T) {
+	tests := []struct {
+		name       string
+		tracestate string
+		remote     bool
+		sampled    bool
+		want       sdktrace.SamplingDecision
+	}{
+		{"remote unsampled kept", "observe=p:1", true, false, sdktrace.RecordAndSample},
+		{"remote sampled dropped", "observe=p:0", true, true, sdktrace.Drop},
+		{"local sampled kept", "observe=p:1", false, true, sdktrace.RecordAndSample},
+		{"local sampled dropped", "observe=p:0", false, true, sdktrace.Drop},
+		{"local unsampled not forced", "observe=p:1", false, false, sdktrace.Drop},
+		{"without priority", "rojo=00f067aa0ba902b7", true, false, sdktrace.Drop},
+	}
+	sampler := prioritySampler{base: sdktrace.ParentBased(sdktrace.AlwaysSample())}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := trace.ParseTraceState(tt.tracestate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flags trace.TraceFlags
+			if tt.sampled {
+				flags = trace.FlagsSampled
+			}
+			parent := trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+				SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+				TraceFlags: flags,
+				TraceState: ts,
+				Remote:     tt.remote,
+			})
+			res := sampler.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: trace.ContextWithSpanContext(context.Background(), parent),
+				TraceID:       parent.TraceID(),
+				Name:          "GET /checkout",
+			})
+			if res.Decision != tt.want {
+				t.Errorf("decision = %v, want %v", res.Decision, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSamplingPriority(t *testing.T) {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(prioritySampler{
+		base: sdktrace.ParentBased(sdktrace.NeverSample()),
+	}))
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(SetSamplingPriority(context.Background(), 1), "root")
+	if !root.SpanContext().IsSampled() {
+		t.Fatal("root with priority 1 not sampled")
+	}
+	if p, ok := samplingPriority(root.SpanContext().TraceState()); !ok || p != 1 {
+		t.Errorf("root tracestate = %q, want the priority recorded", root.SpanContext().TraceState().String())
+	}
+	_, child := tracer.Start(SetSamplingPriority(ctx, 0), "child")
+	if child.SpanContext().IsSampled() {
+		t.Error("child with priority 0 sampled")
+	}
+
+	ctx, dropped := tracer.Start(context.Background(), "dropped")
+	if dropped.SpanContext().IsSampled() {
+		t.Fatal("root without priority sampled")
+	}
+	ctx = SetSamplingPriority(ctx, 1)
+	if _, child := tracer.Start(ctx, "child"); child.SpanContext().IsSampled() {
+		t.Error("local child of an unsampled span forced by priority 1")
+	}
+	remote := trace.SpanContextFromContext(ctx).WithRemote(true)
+	if _, child := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "server"); !child.SpanContext().IsSampled() {
+		t.Error("remote child with priority 1 not sampled")
+	}
+}
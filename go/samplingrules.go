@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// WithSamplerRulesFile samples new traces by rules read from the YAML file
+// at path, and reloads them whenever the file changes, so the trace volume
+// can be adjusted without a redeploy, e.g. from a mounted ConfigMap:
+//
+//	# Ratio for root spans no rule matches, 1 if omitted
+//	default_ratio: 0.1
+//	rules:
+//	  - route: /checkout   # exact path
+//	    ratio: 1
+//	  - route: /static/    # path prefix
+//	    ratio: 0
+//	  - attribute: tenant.id
+//	    value: acme
+//	    ratio: 0.5
+//
+// The first matching rule sets the ratio. Routes are matched against the
+// url.path, http.target or http.route attribute of the root span at start,
+// as set by NewHTTPHandler; attribute rules against the string form of a
+// start attribute. Spans with a parent follow the parent's decision.
+//
+// The file is validated on every load: ratios must be within [0, 1] and
+// each rule needs a route or an attribute with a value. An invalid file
+// fails setup, while an invalid change is logged and the last good rules
+// stay in effect. New rules apply to spans started after the reload.
+func WithSamplerRulesFile(path string) Option {
+	return func(c *config) {
+		c.samplerRulesFile = path
+	}
+}
+
+// rulesFile is the layout of a WithSamplerRulesFile file.
+type rulesFile struct {
+	DefaultRatio *float64 `yaml:"default_ratio"`
+	Rules        []struct {
+		Route     string   `yaml:"route"`
+		Attribute string   `yaml:"attribute"`
+		Value     string   `yaml:"value"`
+		Ratio     *float64 `yaml:"ratio"`
+	} `yaml:"rules"`
+}
+
+// samplingRules is a validated set of rules.
+type samplingRules struct {
+	defaultRatio float64
+	rules        []samplingRule
+}
+
+type samplingRule struct {
+	route     string
+	attribute attribute.Key
+	value     string
+	ratio     float64
+}
+
+// parseSamplingRules decodes and validates a rules file.
+func parseSamplingRules(data []byte) (*samplingRules, error) {
+	var f rulesFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	rules := &samplingRules{defaultRatio: 1}
+	if f.DefaultRatio != nil {
+		if err := checkRatio(*f.DefaultRatio); err != nil {
+			return nil, fmt.Errorf("default_ratio: %w", err)
+		}
+		rules.defaultRatio = *f.DefaultRatio
+	}
+	for i, r := range f.Rules {
+		switch {
+		case r.Ratio == nil:
+			return nil, fmt.Errorf("rule %d: missing ratio", i+1)
+		case (r.Route == "") == (r.Attribute == ""):
+			return nil, fmt.Errorf("rule %d: set either route or attribute", i+1)
+		case r.Attribute != "" && r.Value == "":
+			return nil, fmt.Errorf("rule %d: attribute %q needs a value", i+1, r.Attribute)
+		}
+		if err := checkRatio(*r.Ratio); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+		rules.rules = append(rules.rules, samplingRule{
+			route:     r.Route,
+			attribute: attribute.Key(r.Attribute),
+			value:     r.Value,
+			ratio:     *r.Ratio,
+		})
+	}
+	return rules, nil
+}
+
+func checkRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("ratio %v outside [0, 1]", ratio)
+	}
+	return nil
+}
+
+// ratio returns the ratio of the first rule matching a root span with attrs.
+func (r *samplingRules) ratio(attrs []attribute.KeyValue) float64 {
+	path := spanPath(attrs)
+	for _, rule := range r.rules {
+		if rule.route != "" {
+			if path != "" && (path == rule.route || (strings.HasSuffix(rule.route, "/") && strings.HasPrefix(path, rule.route))) {
+				return rule.ratio
+			}
+			continue
+		}
+		for _, kv := range attrs {
+			if kv.Key == rule.attribute && kv.Value.Emit() == rule.value {
+				return rule.ratio
+			}
+		}
+	}
+	return r.defaultRatio
+}
+
+// spanPath returns the request path among a server span's start attributes.
+func spanPath(attrs []attribute.KeyValue) string {
+	var target, route string
+	for _, kv := range attrs {
+		switch kv.Key {
+		case "url.path":
+			return kv.Value.AsString()
+		case "http.target":
+			target, _, _ = strings.Cut(kv.Value.AsString(), "?")
+		case "http.route":
+			route = kv.Value.AsString()
+		}
+	}
+	if target != "" {
+		return target
+	}
+	return route
+}
+
+// rulesWatcher holds the current rules of a rules file and reloads them when
+// the file changes.
+type rulesWatcher struct {
+	path    string
+	rules   atomic.Pointer[samplingRules]
+	seen    []byte
+	watcher *fsnotify.Watcher
+}
+
+// watchSamplingRules loads the rules file at path and starts watching it.
+// The directory is watched rather than the file, so replacing the file, as
+// editors and Kubernetes volume updates do, is noticed too.
+func watchSamplingRules(cfg *config, path string) (*rulesWatcher, error) {
+	w := &rulesWatcher{path: filepath.Clean(path)}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil, fmt.Errorf("sampler rules file: %w", err)
+	}
+	rules, err := parseSamplingRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("sampler rules file %s: %w", w.path, err)
+	}
+	w.rules.Store(rules)
+	w.seen = data
+
+	if w.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return nil, fmt.Errorf("sampler rules file: %w", err)
+	}
+	if err := w.watcher.Add(filepath.Dir(w.path)); err != nil {
+		w.watcher.Close()
+		return nil, fmt.Errorf("sampler rules file: %w", err)
+	}
+	go w.run(cfg)
+	return w, nil
+}
+
+// run reloads the rules on every change in the file's directory until the
+// watcher is closed.
+func (w *rulesWatcher) run(cfg *config) {
+	for {
+		select {
+		case _, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.reload(cfg)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			cfg.logger.Warn("watching sampler rules file failed", "path", w.path, "error", err)
+		}
+	}
+}
+
+// reload reads the file and switches to its rules if they changed and are
+// valid. Content already seen is skipped, so an invalid file is reported
+// once. Only run calls it, so w.seen needs no lock.
+func (w *rulesWatcher) reload(cfg *config) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			cfg.logger.Warn("failed to read sampler rules file, keeping current rules", "path", w.path, "error", err)
+		}
+		return
+	}
+	if bytes.Equal(data, w.seen) {
+		return
+	}
+	w.seen = data
+	rules, err := parseSamplingRules(data)
+	if err != nil {
+		cfg.logger.Warn("invalid sampler rules file, keeping current rules", "path", w.path, "error", err)
+		return
+	}
+	w.rules.Store(rules)
+	cfg.logger.Info("reloaded sampler rules", "path", w.path, "rules", len(rules.rules), "default_ratio", rules.defaultRatio)
+}
+
+// Close stops watching the file.
+func (w *rulesWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+// rulesSampler drops root spans outside the ratio of the current rules and
+// defers to base for the rest and for spans with a parent.
+type rulesSampler struct {
+	rules *rulesWatcher
+	base  sdktrace.Sampler
+}
+
+func (s rulesSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return s.base.ShouldSample(p)
+	}
+	ratio := s.rules.rules.Load().ratio(p.Attributes)
+	if ratio < 1 && (ratio <= 0 || !sampledByRatio(p.TraceID, ratio)) {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s rulesSampler) Description() string {
+	return "RulesSampler{" + s.rules.path + "," + s.base.Description() + "}"
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, for capturing logs
+// written from background goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// eventually calls cond until it returns true, failing t after a few
+// seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSamplingRulesReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	write := func(content string) {
+		t.Helper()
+		// Replace the file as Kubernetes volume updates do.
+		tmp := filepath.Join(dir, "rules.tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkout := []attribute.KeyValue{attribute.String("url.path", "/checkout")}
+
+	write("default_ratio: 0.1\nrules:\n  - route: /checkout\n    ratio: 1\n")
+	var logs syncBuffer
+	cfg := newConfig(WithInternalLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	w, err := watchSamplingRules(cfg, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if got := w.rules.Load().ratio(checkout); got != 1 {
+		t.Fatalf("ratio = %v, want 1 from the initial rules", got)
+	}
+
+	// An invalid change is reported and the last good rules stay.
+	write("default_ratio: 0.1\nrules:\n  - route: /checkout\n    ratio: 2\n")
+	eventually(t, "the invalid file to be reported", func() bool {
+		return strings.Contains(logs.String(), "invalid sampler rules file")
+	})
+	if got := w.rules.Load().ratio(checkout); got != 1 {
+		t.Errorf("ratio = %v after an invalid change, want the last good 1", got)
+	}
+
+	// A valid change applies.
+	write("default_ratio: 0.1\nrules:\n  - route: /checkout\n    ratio: 0.5\n")
+	eventually(t, "the valid file to be loaded", func() bool {
+		return w.rules.Load().ratio(checkout) == 0.5
+	})
+	if got := w.rules.Load().ratio(nil); got != 0.1 {
+		t.Errorf("default ratio = %v, want 0.1", got)
+	}
+}
+
+func TestSamplingRulesInvalidAtStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	for _, content := range []string{
+		"default_ratio: -1\n",
+		"rules:\n  - ratio: 0.5\n",
+		"rules:\n  - route: /a\n    ratio: 1\n    unknown: x\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if w, err := watchSamplingRules(newConfig(), path); err == nil {
+			w.Close()
+			t.Errorf("rules %q accepted at startup", content)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// WithSeverityAttribute makes the log attribute key, when present on a log
+// call, the authoritative severity of the record, overriding the slog level.
+// String values are matched case-insensitively against trace, debug, info,
+// notice, warn, warning, error, critical and fatal; integer values are taken
+// as OpenTelemetry severity numbers (1-24). The attribute is removed from
+// the record. Unrecognized values leave the slog level in place and the
+// attribute on the record. Only attributes passed to the log call itself
+// are considered, not ones added with Logger.With. The minimum log level
+// applies to the resulting severity, so a Debug call carrying "error" is
+// kept at an info minimum.
+func WithSeverityAttribute(key string) Option {
+	return func(c *config) {
+		c.severityAttribute = key
+	}
+}
+
+// severityNames maps severity names to slog levels. otelslog derives the
+// OpenTelemetry severity number as level+9, so e.g. slog.LevelError+4 is
+// exported as FATAL.
+var severityNames = map[string]slog.Level{
+	"trace":    slog.LevelDebug - 4,
+	"debug":    slog.LevelDebug,
+	"info":     slog.LevelInfo,
+	"notice":   slog.LevelInfo + 2,
+	"warn":     slog.LevelWarn,
+	"warning":  slog.LevelWarn,
+	"error":    slog.LevelError,
+	"critical": slog.LevelError + 2,
+	"fatal":    slog.LevelError + 4,
+}
+
+// severityLevel converts a severity attribute value to a slog level.
+func severityLevel(v slog.Value) (slog.Level, bool) {
+	switch v.Kind() {
+	case slog.KindString:
+		level, ok := severityNames[strings.ToLower(v.String())]
+		return level, ok
+	case slog.KindInt64:
+		if n := v.Int64(); n >= 1 && n <= 24 {
+			return slog.Level(n - 9), true
+		}
+	}
+	return 0, false
+}
+
+// severityHandler sets the level of records carrying the severity
+// attribute from its value.
+type severityHandler struct {
+	slog.Handler
+	key string
+}
+
+// Enabled reports true for every level: the attribute, only visible in
+// Handle, may raise or lower the level of the call.
+func (h severityHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h severityHandler) Handle(ctx context.Context, r slog.Record) error {
+	var (
+		level slog.Level
+		found bool
+	)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == h.key {
+			level, found = severityLevel(a.Value.Resolve())
+			return false
+		}
+		return true
+	})
+	if !found {
+		if !h.Handler.Enabled(ctx, r.Level) {
+			return nil
+		}
+		return h.Handler.Handle(ctx, r)
+	}
+	if !h.Handler.Enabled(ctx, level) {
+		return nil
+	}
+
+	out := slog.NewRecord(r.Time, level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != h.key {
+			out.AddAttrs(a)
+		}
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h severityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return severityHandler{Handler: h.Handler.WithAttrs(attrs), key: h.key}
+}
+
+func (h severityHandler) WithGroup(name string) slog.Handler {
+	return severityHandler{Handler: h.Handler.WithGroup(name), key: h.key}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+// levelRecorder records the levels of the records it handles.
+type levelRecorder struct {
+	levels []slog.Level
+}
+
+func (h *levelRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *levelRecorder) Handle(_ context.Context, r slog.Record) error {
+	h.levels = append(h.levels, r.Level)
+	return nil
+}
+
+func (h *levelRecorder) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *levelRecorder) WithGroup(string) slog.Handler      { return h }
+
+func TestSeverityAttributeOverridesMinimumLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(*slog.Logger)
+		want []slog.Level
+	}{
+		{
+			name: "raised above the minimum",
+			log:  func(l *slog.Logger) { l.Debug("x", "severity", "error") },
+			want: []slog.Level{slog.LevelError},
+		},
+		{
+			name: "lowered below the minimum",
+			log:  func(l *slog.Logger) { l.Error("x", "severity", "debug") },
+		},
+		{
+			name: "no attribute below the minimum",
+			log:  func(l *slog.Logger) { l.Debug("x") },
+		},
+		{
+			name: "no attribute above the minimum",
+			log:  func(l *slog.Logger) { l.Warn("x") },
+			want: []slog.Level{slog.LevelWarn},
+		},
+		{
+			name: "unrecognized value keeps the call level",
+			log:  func(l *slog.Logger) { l.Debug("x", "severity", "loud") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &levelRecorder{}
+			level := new(slog.LevelVar)
+			level.Set(slog.LevelInfo)
+			tt.log(slog.New(severityHandler{
+				Handler: levelHandler{Handler: rec, level: level},
+				key:     "severity",
+			}))
+			if len(rec.levels) != len(tt.want) {
+				t.Fatalf("handled levels = %v, want %v", rec.levels, tt.want)
+			}
+			for i := range tt.want {
+				if rec.levels[i] != tt.want[i] {
+					t.Errorf("handled levels = %v, want %v", rec.levels, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// WithRequestSigner calls sign on every export request right before it is
+// sent, after the exporter has set the final (possibly compressed) body and
+// all headers, so signatures over the body and headers stay valid. sign may
+// add or replace headers; it runs again for every retry. A signer replacing
+// the Authorization header, like SigV4Signer, takes precedence over the
+// bearer token. An error from sign fails the export without sending it.
+func WithRequestSigner(sign func(*http.Request) error) Option {
+	return func(c *config) {
+		c.requestSigner = sign
+	}
+}
+
+// SigV4Signer returns a request signer for WithRequestSigner that signs with
+// AWS Signature Version 4, e.g. for a collector behind API Gateway (service
+// "execute-api") in region. Credentials are retrieved for every request, so
+// pass a cached provider such as the Credentials of aws.Config loaded with
+// config.LoadDefaultConfig:
+//
+//	awsCfg, err := config.LoadDefaultConfig(ctx)
+//	...
+//	Setup("api", WithRequestSigner(SigV4Signer(awsCfg.Credentials, "execute-api", awsCfg.Region)))
+func SigV4Signer(credentials aws.CredentialsProvider, service, region string) func(*http.Request) error {
+	signer := v4.NewSigner()
+	return func(r *http.Request) error {
+		ctx := r.Context()
+		creds, err := credentials.Retrieve(ctx)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			if _, err := io.Copy(hash, body); err != nil {
+				return err
+			}
+		}
+		return signer.SignHTTP(ctx, creds, r, hex.EncodeToString(hash.Sum(nil)), service, region, time.Now())
+	}
+}
+
+// signingTransport signs requests with sign before passing them to base.
+type signingTransport struct {
+	base http.RoundTripper
+	sign func(*http.Request) error
+}
+
+func (t signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The request must not be modified, and the signer needs to read the body
+	// without consuming it. The OTLP exporters set no GetBody, so the body is
+	// buffered; it is already in memory anyway.
+	req = req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		req.Body, _ = req.GetBody()
+		// Send a fixed length rather than chunked, which some gateways reject
+		req.ContentLength = int64(len(body))
+	}
+	if err := t.sign(req); err != nil {
+		return nil, fmt.Errorf("signing export request: %w", err)
+	}
+	return t.base.RoundTrip(req)
+}
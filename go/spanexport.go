@@ -0,0 +1,58 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTraceGroupedBatches keeps the spans of a trace next to each other in
+// each export request instead of in the order they ended, so a backend
+// ingesting a partial batch sees whole runs of a trace. window is how long
+// spans are buffered before a batch is exported; a longer window puts more
+// of each trace into the same batch. A value of 0 keeps the batch timeout
+// of OTEL_BSP_SCHEDULE_DELAY (5s by default).
+//
+// Spans are delayed by up to window before export, and the batch queue
+// holds spans for that long, so long windows raise memory use and, under
+// load, the chance that spans are dropped once OTEL_BSP_MAX_QUEUE_SIZE
+// (2048 by default) is reached. A batch is still sent early when it reaches
+// OTEL_BSP_MAX_EXPORT_BATCH_SIZE spans, so a trace can span several batches.
+func WithTraceGroupedBatches(window time.Duration) Option {
+	return func(c *config) {
+		c.traceGroupedBatches = true
+		c.traceGroupingWindow = window
+	}
+}
+
+// traceGroupingExporter reorders each batch so spans of the same trace are
+// contiguous. Traces keep the order of their first span in the batch and
+// spans keep their order within a trace.
+type traceGroupingExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (e traceGroupingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	order := map[trace.TraceID]int{}
+	grouped := true
+	for i, s := range spans {
+		id := s.SpanContext().TraceID()
+		if _, seen := order[id]; !seen {
+			order[id] = len(order)
+		} else if spans[i-1].SpanContext().TraceID() != id {
+			grouped = false
+		}
+	}
+	if !grouped {
+		// The SDK owns the spans slice; sort a copy.
+		spans = slices.Clone(spans)
+		slices.SortStableFunc(spans, func(a, b sdktrace.ReadOnlySpan) int {
+			return cmp.Compare(order[a.SpanContext().TraceID()], order[b.SpanContext().TraceID()])
+		})
+	}
+	return e.SpanExporter.ExportSpans(ctx, spans)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithMinSpanDuration drops spans shorter than d to cut span volume, unless
+// they have error status or have child spans. Keeping every span with
+// children means no exported span refers to a dropped parent, so traces stay
+// connected in Observe; only short leaf spans are removed. The decision is
+// made when a span ends, so a child started after its parent ended, e.g.
+// from a goroutine outliving the request, does not keep the parent.
+func WithMinSpanDuration(d time.Duration) Option {
+	return func(c *config) {
+		c.minSpanDuration = d
+	}
+}
+
+// minDurationProcessor forwards spans to next unless they are short leaves.
+type minDurationProcessor struct {
+	sdktrace.SpanProcessor
+	min time.Duration
+
+	// parents holds the IDs of live local spans that have children.
+	parents sync.Map
+}
+
+func (p *minDurationProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	// Only track parents that are still running; an entry is removed when its
+	// span ends.
+	if parent := trace.SpanFromContext(ctx); parent.IsRecording() {
+		p.parents.Store(parent.SpanContext().SpanID(), struct{}{})
+	}
+	p.SpanProcessor.OnStart(ctx, s)
+}
+
+func (p *minDurationProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	_, hasChildren := p.parents.LoadAndDelete(s.SpanContext().SpanID())
+	if !hasChildren && s.Status().Code != codes.Error && s.EndTime().Sub(s.StartTime()) < p.min {
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// Default buffer limits for WithLatencySampling and WithErrorSampling.
+const (
+	defaultTailMaxTraces = 1000
+	defaultTailMaxSpans  = 1000
+	// defaultErrorSamplingTimeout bounds how long WithErrorSampling waits
+	// for a local root span to end.
+	defaultErrorSamplingTimeout = 30 * time.Second
+)
+
+// WithLatencySampling keeps only traces whose local root span, i.e. the
+// first span of the trace in this process, lasts at least threshold. The
+// spans of each trace are held in memory until its local root ends and then
+// exported or dropped together. This approximates tail sampling inside the
+// process: the decision only sees the local part of the trace, and other
+// services sample independently.
+//
+// At most maxTraces traces and maxSpans spans per trace are buffered (0 uses
+// 1000 for either). Traces beyond these limits are exported unconditionally,
+// so memory stays bounded at the cost of keeping some fast traces. Spans
+// that end after their local root are exported as well.
+func WithLatencySampling(threshold time.Duration, maxTraces, maxSpans int) Option {
+	keep := func(_ *bufferedTrace, d time.Duration) bool { return d >= threshold }
+	return func(c *config) {
+		c.latencySampling = newTailSamplingProcessor(keep, maxTraces, maxSpans, 0)
+	}
+}
+
+// WithErrorSampling keeps only traces in which at least one span has Error
+// status, dropping traces that completed successfully. Like
+// WithLatencySampling, it holds the spans of each trace in memory until its
+// local root span ends, then exports or drops them together, and decides on
+// the local part of the trace only.
+//
+// Unlike head sampling, which decides when a trace starts and costs nothing
+// for dropped traces, this records every span and buffers it until the
+// trace is complete: memory grows with the number of concurrent traces and
+// their span counts, and spans reach the backend only once their local root
+// ends. Services called by this one do not learn the decision, so their
+// spans of a dropped trace are still exported unless they drop it too.
+//
+// At most maxTraces traces and maxSpans spans per trace are buffered (0 uses
+// 1000 for either); traces beyond these limits are exported unconditionally.
+// A trace whose local root has not ended after timeout (0 uses 30s), e.g.
+// a long-running or leaked span, is decided on the spans ended so far.
+// Spans that end after their trace was decided are exported. Combined with
+// WithLatencySampling, a trace is kept only if it is both slow and failed.
+func WithErrorSampling(maxTraces, maxSpans int, timeout time.Duration) Option {
+	if timeout <= 0 {
+		timeout = defaultErrorSamplingTimeout
+	}
+	keep := func(t *bufferedTrace, _ time.Duration) bool { return t.hasError }
+	return func(c *config) {
+		c.errorSampling = newTailSamplingProcessor(keep, maxTraces, maxSpans, timeout)
+	}
+}
+
+// bufferedTrace holds the ended spans of a trace whose root is still running.
+type bufferedTrace struct {
+	spans []sdktrace.ReadOnlySpan
+	// hasError is set once any span of the trace ended with Error status.
+	hasError bool
+	// passthrough is set once the trace exceeded the span limit; its spans
+	// are then forwarded as they end.
+	passthrough bool
+	// timer expires the trace if its root does not end in time.
+	timer *time.Timer
+}
+
+// tailSamplingProcessor buffers traces until their local root ends and
+// forwards them to next only if keep accepts them. keep is passed the
+// duration of the local root, or the timeout for traces whose root is still
+// running when it expires.
+type tailSamplingProcessor struct {
+	sdktrace.SpanProcessor
+	keep      func(t *bufferedTrace, rootDuration time.Duration) bool
+	maxTraces int
+	maxSpans  int
+	timeout   time.Duration
+	// onDrop, if set, is called with the ID of each dropped trace, so a
+	// tail sampler behind this one can release its buffer of the trace:
+	// it never sees the dropped spans.
+	onDrop func(trace.TraceID)
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*bufferedTrace
+}
+
+func newTailSamplingProcessor(keep func(*bufferedTrace, time.Duration) bool, maxTraces, maxSpans int, timeout time.Duration) *tailSamplingProcessor {
+	if maxTraces <= 0 {
+		maxTraces = defaultTailMaxTraces
+	}
+	if maxSpans <= 0 {
+		maxSpans = defaultTailMaxSpans
+	}
+	return &tailSamplingProcessor{
+		keep:      keep,
+		maxTraces: maxTraces,
+		maxSpans:  maxSpans,
+		timeout:   timeout,
+		traces:    map[trace.TraceID]*bufferedTrace{},
+	}
+}
+
+// isLocalRoot reports whether s has no parent in this process.
+func isLocalRoot(parent trace.SpanContext) bool {
+	return !parent.IsValid() || parent.IsRemote()
+}
+
+func (p *tailSamplingProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	if isLocalRoot(s.Parent()) {
+		tid := s.SpanContext().TraceID()
+		p.mu.Lock()
+		if len(p.traces) < p.maxTraces {
+			t := &bufferedTrace{}
+			if p.timeout > 0 {
+				t.timer = time.AfterFunc(p.timeout, func() { p.expire(tid, t) })
+			}
+			p.traces[tid] = t
+		}
+		p.mu.Unlock()
+	}
+	p.SpanProcessor.OnStart(ctx, s)
+}
+
+func (p *tailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	tid := s.SpanContext().TraceID()
+	p.mu.Lock()
+	t, ok := p.traces[tid]
+	if !ok {
+		p.mu.Unlock()
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	if s.Status().Code == codes.Error {
+		t.hasError = true
+	}
+
+	var (
+		forward []sdktrace.ReadOnlySpan
+		dropped bool
+	)
+	switch {
+	case isLocalRoot(s.Parent()):
+		delete(p.traces, tid)
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		if t.passthrough || p.keep(t, s.EndTime().Sub(s.StartTime())) {
+			forward = append(t.spans, s)
+		} else {
+			dropped = true
+		}
+	case t.passthrough:
+		forward = []sdktrace.ReadOnlySpan{s}
+	case len(t.spans) >= p.maxSpans:
+		t.passthrough = true
+		forward = append(t.spans, s)
+		t.spans = nil
+	default:
+		t.spans = append(t.spans, s)
+	}
+	p.mu.Unlock()
+
+	if dropped && p.onDrop != nil {
+		p.onDrop(tid)
+	}
+	for _, span := range forward {
+		p.SpanProcessor.OnEnd(span)
+	}
+}
+
+// expire decides trace t, identified by tid, on the spans ended so far,
+// unless its root ended first.
+func (p *tailSamplingProcessor) expire(tid trace.TraceID, t *bufferedTrace) {
+	p.mu.Lock()
+	if p.traces[tid] != t {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.traces, tid)
+	keep := t.passthrough || p.keep(t, p.timeout)
+	p.mu.Unlock()
+
+	if !keep {
+		if p.onDrop != nil {
+			p.onDrop(tid)
+		}
+		return
+	}
+	for _, span := range t.spans {
+		p.SpanProcessor.OnEnd(span)
+	}
+}
+
+// discard forgets the trace identified by tid without exporting its spans.
+func (p *tailSamplingProcessor) discard(tid trace.TraceID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.traces[tid]; ok {
+		delete(p.traces, tid)
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+}
+
+// withTailSampling puts the tail samplers configured in cfg in front of
+// next, latency sampling outermost.
+func withTailSampling(cfg *config, next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	if cfg.errorSampling != nil {
+		cfg.errorSampling.SpanProcessor = next
+		next = cfg.errorSampling
+	}
+	if cfg.latencySampling != nil {
+		cfg.latencySampling.SpanProcessor = next
+		if cfg.errorSampling != nil {
+			cfg.latencySampling.onDrop = cfg.errorSampling.discard
+		}
+		next = cfg.latencySampling
+	}
+	return next
+}
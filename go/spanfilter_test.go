@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// endTrace records a trace of a root with one child, the root lasting d,
+// and returns the trace ID.
+func endTrace(tracer trace.Tracer, d time.Duration, failed bool) trace.TraceID {
+	start := time.Now()
+	ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+	_, child := tracer.Start(ctx, "child", trace.WithTimestamp(start))
+	if failed {
+		child.SetStatus(codes.Error, "failed")
+	}
+	child.End(trace.WithTimestamp(start.Add(d / 2)))
+	root.End(trace.WithTimestamp(start.Add(d)))
+	return root.SpanContext().TraceID()
+}
+
+// exportedTraces returns the number of spans rec got for each trace.
+func exportedTraces(rec *tracetest.SpanRecorder) map[trace.TraceID]int {
+	got := map[trace.TraceID]int{}
+	for _, s := range rec.Ended() {
+		got[s.SpanContext().TraceID()]++
+	}
+	return got
+}
+
+func TestLatencyAndErrorSamplingCombined(t *testing.T) {
+	cfg := newConfig(
+		WithLatencySampling(time.Second, 0, 0),
+		WithErrorSampling(2, 0, time.Hour),
+	)
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(withTailSampling(cfg, rec)))
+	tracer := tp.Tracer("test")
+
+	// Fast traces dropped by latency sampling must not stay buffered in
+	// error sampling, or it would turn pass-through once full.
+	for range 5 {
+		endTrace(tracer, time.Millisecond, true)
+	}
+	if n := len(cfg.errorSampling.traces); n != 0 {
+		t.Errorf("error sampling still buffers %d traces dropped by latency sampling", n)
+	}
+
+	slowOK := endTrace(tracer, 2*time.Second, false)
+	slowFailed := endTrace(tracer, 2*time.Second, true)
+	got := exportedTraces(rec)
+	if got[slowOK] != 0 {
+		t.Error("slow successful trace exported")
+	}
+	if got[slowFailed] != 2 {
+		t.Errorf("slow failed trace exported %d spans, want 2", got[slowFailed])
+	}
+	if len(got) != 1 {
+		t.Errorf("exported %d traces, want only the slow failed one", len(got))
+	}
+}
+
+func TestLatencySamplingBufferLimits(t *testing.T) {
+	cfg := newConfig(WithLatencySampling(time.Second, 2, 3))
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(withTailSampling(cfg, rec)))
+	tracer := tp.Tracer("test")
+
+	// Two traces fill the buffer; a third is exported unconditionally.
+	start := time.Now()
+	var roots []trace.Span
+	for range 3 {
+		_, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+		roots = append(roots, root)
+	}
+	for _, root := range roots {
+		root.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+	}
+	got := exportedTraces(rec)
+	if len(got) != 1 || got[roots[2].SpanContext().TraceID()] != 1 {
+		t.Errorf("exported %v, want only the fast trace beyond the buffer limit", got)
+	}
+	if n := len(cfg.latencySampling.traces); n != 0 {
+		t.Errorf("%d traces still buffered after their roots ended", n)
+	}
+
+	// A fast trace with more spans than the limit is exported from then on.
+	rec.Reset()
+	ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+	for range 5 {
+		_, child := tracer.Start(ctx, "child", trace.WithTimestamp(start))
+		child.End(trace.WithTimestamp(start))
+	}
+	if n := len(rec.Ended()); n != 5 {
+		t.Errorf("exported %d spans before the root ended, want all 5 once the limit of 3 was exceeded", n)
+	}
+	root.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+	if n := len(rec.Ended()); n != 6 {
+		t.Errorf("exported %d spans of the trace over the span limit, want all 6", n)
+	}
+
+	// Spans ending after their root are exported.
+	rec.Reset()
+	ctx, root = tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+	_, late := tracer.Start(ctx, "late", trace.WithTimestamp(start))
+	root.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+	late.End()
+	if got := rec.Ended(); len(got) != 1 || got[0].Name() != "late" {
+		t.Errorf("exported %d spans, want only the one ending after the dropped root", len(got))
+	}
+}
+
+func TestErrorSamplingTimeout(t *testing.T) {
+	cfg := newConfig(WithErrorSampling(0, 0, 50*time.Millisecond))
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(withTailSampling(cfg, rec)))
+	tracer := tp.Tracer("test")
+
+	// A failed trace whose root never ends is exported on the spans ended
+	// so far once the timeout passes.
+	ctx, leaked := tracer.Start(context.Background(), "leaked")
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+	// A successful one is dropped.
+	ctx, slow := tracer.Start(context.Background(), "slow")
+	_, ok := tracer.Start(ctx, "ok")
+	ok.End()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cfg.errorSampling.mu.Lock()
+		buffered := len(cfg.errorSampling.traces)
+		cfg.errorSampling.mu.Unlock()
+		if buffered == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d traces still buffered after the timeout", buffered)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := rec.Ended(); len(got) != 1 || got[0].Name() != "child" {
+		t.Fatalf("exported %d spans on timeout, want the failed child only", len(got))
+	}
+
+	// Roots ending after the timeout are exported as late spans.
+	leaked.End()
+	slow.End()
+	if n := len(rec.Ended()); n != 3 {
+		t.Errorf("exported %d spans, want the roots ending after the timeout too", n)
+	}
+}
+
+func TestMinSpanDurationKeepsParents(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	filter := &minDurationProcessor{SpanProcessor: rec, min: time.Second}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(filter))
+	tracer := tp.Tracer("test")
+	start := time.Now()
+	short := trace.WithTimestamp(start.Add(time.Millisecond))
+
+	// request (slow) > handler (short) > cache (short leaf), and a short
+	// failed leaf.
+	ctx, request := tracer.Start(context.Background(), "request", trace.WithTimestamp(start))
+	ctx2, handler := tracer.Start(ctx, "handler", trace.WithTimestamp(start))
+	_, cache := tracer.Start(ctx2, "cache", trace.WithTimestamp(start))
+	cache.End(short)
+	handler.End(short)
+	_, failed := tracer.Start(ctx, "failed", trace.WithTimestamp(start))
+	failed.SetStatus(codes.Error, "failed")
+	failed.End(short)
+	request.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+
+	var names []string
+	for _, s := range rec.Ended() {
+		names = append(names, s.Name())
+	}
+	if want := []string{"handler", "failed", "request"}; !slices.Equal(names, want) {
+		t.Errorf("exported %v, want %v", names, want)
+	}
+
+	// A short span whose child is still running when it ends is kept, and
+	// the tracking of a parent ends with it.
+	rec.Reset()
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithTimestamp(start))
+	_, child := tracer.Start(ctx, "child", trace.WithTimestamp(start))
+	parent.End(short)
+	child.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+	if n := len(rec.Ended()); n != 2 {
+		t.Errorf("exported %d spans, want the short parent of a running child and the child", n)
+	}
+
+	// A child started after its short parent ended cannot keep it.
+	rec.Reset()
+	ctx, parent = tracer.Start(context.Background(), "parent", trace.WithTimestamp(start))
+	parent.End(short)
+	_, child = tracer.Start(ctx, "child", trace.WithTimestamp(start))
+	child.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+	if got := rec.Ended(); len(got) != 1 || got[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("exported %d spans, want only the late child of the dropped parent", len(got))
+	}
+
+	filter.parents.Range(func(id, _ any) bool {
+		t.Errorf("parent %v still tracked after it ended", id)
+		return true
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSpan starts a span on the global tracer. Without trace.WithSpanKind
+// in opts the span is Internal. Prefer the kind-specific helpers below for
+// spans that cross a process boundary: the span kind is how backends tell
+// calls between services apart from work inside one, and Observe derives the
+// service map from Server/Client and Producer/Consumer pairs.
+// Call setupInstrumentation first.
+func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return appTracer.Start(ctx, name, opts...)
+}
+
+// StartServerSpan starts a Server span, for handling an incoming
+// synchronous request such as an RPC or HTTP call.
+func StartServerSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return startSpanOfKind(ctx, name, trace.SpanKindServer, opts)
+}
+
+// StartClientSpan starts a Client span, for an outgoing synchronous request
+// to another service.
+func StartClientSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return startSpanOfKind(ctx, name, trace.SpanKindClient, opts)
+}
+
+// StartProducerSpan starts a Producer span, for publishing a message that is
+// processed asynchronously, e.g. to a queue or topic.
+func StartProducerSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return startSpanOfKind(ctx, name, trace.SpanKindProducer, opts)
+}
+
+// StartConsumerSpan starts a Consumer span, for processing a message
+// published by a producer. Extract the producer's context from the message
+// into ctx first so both ends join one trace.
+func StartConsumerSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return startSpanOfKind(ctx, name, trace.SpanKindConsumer, opts)
+}
+
+// startSpanOfKind starts a span of kind. The kind is applied last, so it wins
+// over a trace.WithSpanKind in opts.
+func startSpanOfKind(ctx context.Context, name string, kind trace.SpanKind, opts []trace.SpanStartOption) (context.Context, trace.Span) {
+	return appTracer.Start(ctx, name, append(slices.Clip(opts), trace.WithSpanKind(kind))...)
+}
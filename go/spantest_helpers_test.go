@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testSpans holds the spans recorded since SetupForTest, for the assertion
+// helpers below.
+var testSpans *tracetest.InMemoryExporter
+
+// SetupForTest installs a tracer provider that keeps ended spans in memory
+// instead of exporting them, for tests of instrumented code. Spans are
+// recorded on End, so they can be checked right away with the Assert
+// helpers or the returned exporter. opts configure sampling as for Setup.
+// The previous provider is restored when t ends. Metrics and logs are not
+// set up. Tests using it must not run in parallel, as it replaces global
+// state.
+func SetupForTest(t testing.TB, opts ...Option) *tracetest.InMemoryExporter {
+	t.Helper()
+	cfg := newConfig(opts...)
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(newSampler(cfg)),
+	)
+
+	prevProvider, prevTracer, prevSpans := otel.GetTracerProvider(), appTracer, testSpans
+	otel.SetTracerProvider(tp)
+	appTracer, testSpans = tp.Tracer(t.Name()), exporter
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		appTracer, testSpans = prevTracer, prevSpans
+	})
+	return exporter
+}
+
+// SpanNode is a span with the spans started as its direct children.
+type SpanNode struct {
+	Span     tracetest.SpanStub
+	Children []*SpanNode
+}
+
+// BuildSpanTree arranges spans into trees by parent span ID, returning the
+// spans whose parent is not among spans, such as local roots, in the order
+// given. Children are ordered by start time.
+func BuildSpanTree(spans tracetest.SpanStubs) []*SpanNode {
+	nodes := make(map[trace.SpanID]*SpanNode, len(spans))
+	for _, s := range spans {
+		nodes[s.SpanContext.SpanID()] = &SpanNode{Span: s}
+	}
+	var roots []*SpanNode
+	for _, s := range spans {
+		node := nodes[s.SpanContext.SpanID()]
+		if parent, ok := nodes[s.Parent.SpanID()]; ok && s.Parent.IsValid() {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, node := range nodes {
+		slices.SortStableFunc(node.Children, func(a, b *SpanNode) int {
+			return a.Span.StartTime.Compare(b.Span.StartTime)
+		})
+	}
+	return roots
+}
+
+// AssertSpanHasChild fails t unless a span named parentName recorded since
+// SetupForTest has a direct child named childName.
+func AssertSpanHasChild(t testing.TB, parentName, childName string) {
+	t.Helper()
+	roots := BuildSpanTree(recordedSpans(t))
+	var found bool
+	walkSpanTree(roots, func(n *SpanNode) {
+		if n.Span.Name != parentName {
+			return
+		}
+		for _, c := range n.Children {
+			found = found || c.Span.Name == childName
+		}
+	})
+	if !found {
+		t.Errorf("no span %q with child %q; recorded spans:\n%s", parentName, childName, formatSpanTree(roots))
+	}
+}
+
+// AssertSpanAttribute fails t unless a span named name recorded since
+// SetupForTest has attribute key set to value. Integer and float values
+// match attributes of any integer or float width, e.g. 200 matches
+// attribute.Int64("http.status_code", 200).
+func AssertSpanAttribute(t testing.TB, name, key string, value any) {
+	t.Helper()
+	spans := recordedSpans(t)
+	want := normalizeAttributeValue(value)
+	var got []string
+	for _, s := range spans {
+		if s.Name != name {
+			continue
+		}
+		for _, kv := range s.Attributes {
+			if string(kv.Key) != key {
+				continue
+			}
+			if reflect.DeepEqual(kv.Value.AsInterface(), want) {
+				return
+			}
+			got = append(got, kv.Value.Emit())
+		}
+	}
+	if len(got) > 0 {
+		t.Errorf("span %q has %s=%s, want %v", name, key, strings.Join(got, ", "), value)
+		return
+	}
+	t.Errorf("no span %q with attribute %s; recorded spans:\n%s", name, key, formatSpanTree(BuildSpanTree(spans)))
+}
+
+// recordedSpans returns the spans recorded since SetupForTest.
+func recordedSpans(t testing.TB) tracetest.SpanStubs {
+	t.Helper()
+	if testSpans == nil {
+		t.Fatal("span assertions need SetupForTest")
+	}
+	return testSpans.GetSpans()
+}
+
+// normalizeAttributeValue converts v, a Go value of a type attributes are
+// built from, to the type attribute.Value.AsInterface returns for it: any
+// integer or float width and slices of them become int64, float64, []int64
+// or []float64.
+func normalizeAttributeValue(v any) any {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []int:
+		return toInt64s(v)
+	case []int8:
+		return toInt64s(v)
+	case []int16:
+		return toInt64s(v)
+	case []int32:
+		return toInt64s(v)
+	case []uint:
+		return toInt64s(v)
+	case []uint16:
+		return toInt64s(v)
+	case []uint32:
+		return toInt64s(v)
+	case []uint64:
+		return toInt64s(v)
+	case []float32:
+		out := make([]float64, len(v))
+		for i, f := range v {
+			out[i] = float64(f)
+		}
+		return out
+	case attribute.Value:
+		return v.AsInterface()
+	}
+	return v
+}
+
+// toInt64s converts a slice of integers to int64.
+func toInt64s[T int | int8 | int16 | int32 | uint | uint16 | uint32 | uint64](v []T) []int64 {
+	out := make([]int64, len(v))
+	for i, n := range v {
+		out[i] = int64(n)
+	}
+	return out
+}
+
+// walkSpanTree calls fn for every node of the trees, parents first.
+func walkSpanTree(nodes []*SpanNode, fn func(*SpanNode)) {
+	for _, n := range nodes {
+		fn(n)
+		walkSpanTree(n.Children, fn)
+	}
+}
+
+// formatSpanTree renders the trees as an indented list of span names, for
+// failure messages.
+func formatSpanTree(nodes []*SpanNode) string {
+	var b strings.Builder
+	var write func([]*SpanNode, int)
+	write = func(nodes []*SpanNode, depth int) {
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "%s- %s\n", strings.Repeat("  ", depth), n.Span.Name)
+			write(n.Children, depth+1)
+		}
+	}
+	write(nodes, 0)
+	if b.Len() == 0 {
+		return "  (none)\n"
+	}
+	return b.String()
+}
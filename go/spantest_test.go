@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// failureRecorder is a testing.TB that records failures instead of
+// reporting them, to check that the assertion helpers fail when they should.
+type failureRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *failureRecorder) Helper() {}
+
+func (r *failureRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// startCheckout records a server span with two children, the second with a
+// grandchild, through the tracer SetupForTest installs.
+func startCheckout() {
+	ctx, root := GetTracer().Start(context.Background(), "GET /checkout")
+	root.SetAttributes(attribute.Int64("http.status_code", 200), attribute.String("http.route", "/checkout"))
+
+	_, auth := GetTracer().Start(ctx, "auth")
+	auth.End()
+	ctx2, charge := GetTracer().Start(ctx, "charge")
+	charge.SetAttributes(attribute.Float64("amount", 12.5), attribute.IntSlice("items", []int{1, 2}))
+	_, db := GetTracer().Start(ctx2, "db.insert")
+	db.End()
+	charge.End()
+	root.End()
+}
+
+func TestSetupForTestRecordsSpans(t *testing.T) {
+	exporter := SetupForTest(t)
+	startCheckout()
+	if got := len(exporter.GetSpans()); got != 4 {
+		t.Fatalf("recorded %d spans, want 4", got)
+	}
+}
+
+func TestSetupForTestRestoresProvider(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Run("inner", func(t *testing.T) {
+		SetupForTest(t)
+		if otel.GetTracerProvider() == prev {
+			t.Error("SetupForTest did not install a tracer provider")
+		}
+	})
+	if otel.GetTracerProvider() != prev {
+		t.Error("tracer provider not restored when the test ended")
+	}
+	if testSpans != nil {
+		t.Error("recorded spans still used after the test ended")
+	}
+}
+
+func TestSetupForTestSampling(t *testing.T) {
+	exporter := SetupForTest(t, WithDropOrphanSpans("GET /checkout"))
+	startCheckout()
+	if got := len(exporter.GetSpans()); got != 0 {
+		t.Errorf("recorded %d spans of a dropped trace, want 0", got)
+	}
+}
+
+func TestBuildSpanTree(t *testing.T) {
+	exporter := SetupForTest(t)
+	startCheckout()
+
+	roots := BuildSpanTree(exporter.GetSpans())
+	if len(roots) != 1 || roots[0].Span.Name != "GET /checkout" {
+		t.Fatalf("roots = %s", formatSpanTree(roots))
+	}
+	want := "- GET /checkout\n  - auth\n  - charge\n    - db.insert\n"
+	if got := formatSpanTree(roots); got != want {
+		t.Errorf("tree =\n%swant\n%s", got, want)
+	}
+}
+
+func TestAssertSpanHasChild(t *testing.T) {
+	SetupForTest(t)
+	startCheckout()
+
+	AssertSpanHasChild(t, "GET /checkout", "charge")
+	AssertSpanHasChild(t, "charge", "db.insert")
+
+	r := &failureRecorder{TB: t}
+	AssertSpanHasChild(r, "GET /checkout", "db.insert")
+	AssertSpanHasChild(r, "auth", "charge")
+	if len(r.errors) != 2 {
+		t.Fatalf("got %d failures for a grandchild and a missing child, want 2: %q", len(r.errors), r.errors)
+	}
+	if !strings.Contains(r.errors[0], "- GET /checkout\n  - auth") {
+		t.Errorf("failure %q does not list the recorded spans", r.errors[0])
+	}
+}
+
+func TestAssertSpanAttribute(t *testing.T) {
+	SetupForTest(t)
+	startCheckout()
+
+	AssertSpanAttribute(t, "GET /checkout", "http.status_code", 200)
+	AssertSpanAttribute(t, "GET /checkout", "http.route", "/checkout")
+	AssertSpanAttribute(t, "charge", "amount", float32(12.5))
+	AssertSpanAttribute(t, "charge", "items", []int{1, 2})
+	AssertSpanAttribute(t, "charge", "amount", attribute.Float64Value(12.5))
+	AssertSpanAttribute(t, "GET /checkout", "http.status_code", uint16(200))
+	AssertSpanAttribute(t, "charge", "items", []int8{1, 2})
+
+	r := &failureRecorder{TB: t}
+	AssertSpanAttribute(r, "GET /checkout", "http.status_code", 500)
+	AssertSpanAttribute(r, "auth", "http.route", "/checkout")
+	if len(r.errors) != 2 {
+		t.Fatalf("got %d failures for a wrong value and a missing attribute, want 2: %q", len(r.errors), r.errors)
+	}
+	if want := `span "GET /checkout" has http.status_code=200, want 500`; r.errors[0] != want {
+		t.Errorf("failure = %q, want %q", r.errors[0], want)
+	}
+	if !strings.HasPrefix(r.errors[1], `no span "auth" with attribute http.route`) {
+		t.Errorf("failure = %q", r.errors[1])
+	}
+}
+
+func TestNormalizeAttributeValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  attribute.Value
+	}{
+		{int8(-3), attribute.Int64Value(-3)},
+		{int16(300), attribute.Int64Value(300)},
+		{uint(7), attribute.Int64Value(7)},
+		{uint8(200), attribute.Int64Value(200)},
+		{uint32(70000), attribute.Int64Value(70000)},
+		{uint64(1 << 40), attribute.Int64Value(1 << 40)},
+		{[]uint16{1, 2}, attribute.Int64SliceValue([]int64{1, 2})},
+		{[]int32{-1}, attribute.Int64SliceValue([]int64{-1})},
+		{[]float32{0.5, 1.5}, attribute.Float64SliceValue([]float64{0.5, 1.5})},
+		{[]string{"a"}, attribute.StringSliceValue([]string{"a"})},
+	}
+	for _, tt := range tests {
+		if got := normalizeAttributeValue(tt.value); !reflect.DeepEqual(got, tt.want.AsInterface()) {
+			t.Errorf("normalizeAttributeValue(%#v) = %#v, want %#v", tt.value, got, tt.want.AsInterface())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithSRVEndpoint sends exports to the collectors listed in the DNS SRV
+// record name, e.g. "_otlp._tcp.collectors.example.com", instead of the host
+// and port of the configured endpoint. The endpoint's scheme and paths still
+// apply, and its host is still used for the Host header and TLS server name.
+//
+// The record is resolved at setup, and again when a new connection is opened
+// more than refresh after the last lookup; with a refresh of 0 it is
+// resolved only once. Idle connections are reused as usual, so targets only
+// change as connections are replaced. New connections go to the targets with
+// the lowest priority number, rotating between them; if none of them can be
+// reached, the next priority is tried. If a lookup fails the previous targets are kept and
+// lookup is retried on the next connection, so a DNS outage does not stop a
+// running service from exporting.
+func WithSRVEndpoint(name string, refresh time.Duration) Option {
+	return func(c *config) {
+		c.srv = &srvResolver{name: name, refresh: refresh, lookup: net.DefaultResolver.LookupSRV}
+	}
+}
+
+// srvResolver caches the targets of an SRV record. It is shared by the
+// exporters of all signals.
+type srvResolver struct {
+	name    string
+	refresh time.Duration
+	lookup  func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	mu       sync.Mutex
+	targets  []*net.SRV
+	resolved time.Time
+	next     int
+}
+
+// resolve looks the record up if it was never resolved or is due for a
+// refresh, and returns the current targets ordered by priority.
+func (r *srvResolver) resolve(ctx context.Context, logger *slog.Logger) ([]*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stale := r.targets == nil || (r.refresh > 0 && time.Since(r.resolved) >= r.refresh)
+	if stale {
+		// LookupSRV sorts by priority and randomizes by weight within one.
+		_, targets, err := r.lookup(ctx, "", "", r.name)
+		switch {
+		case err == nil && len(targets) > 0:
+			r.targets, r.resolved = targets, time.Now()
+		case r.targets == nil:
+			if err == nil {
+				err = errors.New("no SRV targets")
+			}
+			return nil, err
+		default:
+			logger.Warn("SRV lookup failed, keeping previous collector targets", "name", r.name, "error", err)
+		}
+	}
+	return r.targets, nil
+}
+
+// order returns targets with the lowest-priority group rotated so that
+// successive connections start at a different target.
+func (r *srvResolver) order(targets []*net.SRV) []*net.SRV {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	first := 0
+	for first < len(targets) && targets[first].Priority == targets[0].Priority {
+		first++
+	}
+	start := r.next % first
+	r.next++
+
+	ordered := make([]*net.SRV, 0, len(targets))
+	ordered = append(ordered, targets[start:first]...)
+	ordered = append(ordered, targets[:start]...)
+	return append(ordered, targets[first:]...)
+}
+
+// dialer returns a DialContext function connecting to the SRV targets,
+// trying them in order until one accepts the connection.
+func (r *srvResolver) dialer(logger *slog.Logger) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, network, _ string) (net.Conn, error) {
+		targets, err := r.resolve(ctx, logger)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, t := range r.order(targets) {
+			conn, err := d.DialContext(ctx, network, net.JoinHostPort(t.Target, strconv.Itoa(int(t.Port))))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// processStartTime approximates when the process started.
+var processStartTime = time.Now()
+
+// WithStartupEvent emits a "service.start" log record and a zero-duration
+// "service.start" span once setup completes, carrying the service version,
+// the VCS revision (see WithGitCommit) and the process start time. This gives
+// a clear deploy marker when a new version comes online.
+func WithStartupEvent() Option {
+	return func(c *config) {
+		c.startupEvent = true
+	}
+}
+
+// emitStartupEvent records the startup log and span.
+func emitStartupEvent(ctx context.Context, serviceName string) {
+	version, _ := appResource.Set().Value(ServiceVersionKey)
+	revision, _ := appResource.Set().Value(VCSRevisionKey)
+
+	appLogger.InfoContext(ctx, "service.start",
+		"service.name", serviceName,
+		"service.version", version.AsString(),
+		"vcs.revision", revision.AsString(),
+		"process.start_time", processStartTime)
+
+	_, span := appTracer.Start(ctx, "service.start",
+		trace.WithTimestamp(processStartTime),
+		trace.WithAttributes(
+			attribute.String("service.version", version.AsString()),
+			attribute.String("vcs.revision", revision.AsString()),
+		),
+	)
+	span.End(trace.WithTimestamp(processStartTime))
+}
+
+// WithSelfTracing records a span named "otel.setup" that covers setup from
+// the start, including resource detection and exporter creation, to the
+// point where all providers are installed, to track startup time. A signal
+// that failed to initialize is recorded as an exception event carrying the
+// signal name, and the span status is then Error. The span is only
+// recorded if tracing itself came up; it is created after the fact with
+// the setup's start time, so it costs nothing while setup runs.
+func WithSelfTracing() Option {
+	return func(c *config) {
+		c.selfTracing = true
+	}
+}
+
+// emitSetupSpan records the WithSelfTracing span for a setup that started
+// at start and failed with setupErr for some signals.
+func emitSetupSpan(ctx context.Context, start time.Time, setupErr *SetupError) {
+	_, span := otel.Tracer(selfScopeName).Start(ctx, "otel.setup", trace.WithTimestamp(start))
+	for _, s := range []struct {
+		signal string
+		err    error
+	}{{signalTraces, setupErr.Traces}, {signalMetrics, setupErr.Metrics}, {signalLogs, setupErr.Logs}} {
+		if s.err != nil {
+			span.RecordError(s.err, trace.WithAttributes(attribute.String("signal", s.signal)))
+		}
+	}
+	if setupErr.failed() {
+		span.SetStatus(codes.Error, setupErr.Error())
+	}
+	span.End()
+}
+
+// buildVCSRevision returns the VCS revision stamped into the binary by the Go
+// toolchain, or "" when it is unavailable (e.g. go run, or -buildvcs=false).
+func buildVCSRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// syslogRetryInterval is how long the syslog sink waits before reconnecting
+// after the daemon could not be reached. Records logged meanwhile are
+// dropped.
+const syslogRetryInterval = 30 * time.Second
+
+// Syslog severities (RFC 5424), independent of the platform's log/syslog.
+const (
+	syslogEmerg = iota
+	syslogAlert
+	syslogCrit
+	syslogErr
+	syslogWarning
+	syslogNotice
+	syslogInfo
+	syslogDebug
+)
+
+// WithSyslogSink writes every log record to syslog in addition to OTLP, e.g.
+// while migrating from syslog. network and addr are passed to
+// log/syslog.Dial; leave both empty for the local daemon. Records are sent
+// with the service name as tag and the message and attributes in logfmt, at
+// the syslog severity matching their OpenTelemetry severity as in the
+// OpenTelemetry log data model: FATAL is emerg, ERROR3 and ERROR4 alert,
+// ERROR2 crit, ERROR err, WARN warning, INFO2 to INFO4 notice, INFO info and
+// lower severities debug. Many daemons broadcast emerg to all terminals.
+//
+// A daemon that cannot be reached does not fail setup: the failure is logged,
+// records are dropped and the connection is retried every 30 seconds. Syslog
+// is only available on Unix systems; elsewhere the option logs a warning and
+// has no effect.
+func WithSyslogSink(network, addr string) Option {
+	return func(c *config) {
+		c.syslog = &syslogTarget{network: network, addr: addr}
+	}
+}
+
+// syslogTarget is the daemon selected by WithSyslogSink.
+type syslogTarget struct {
+	network string
+	addr    string
+}
+
+// syslogSeverity maps a slog level to a syslog severity, going through the
+// OpenTelemetry severity number otelslog derives from it (level+9).
+func syslogSeverity(level slog.Level) int {
+	switch sev := int(level) + 9; {
+	case sev >= 21:
+		return syslogEmerg
+	case sev >= 19:
+		return syslogAlert
+	case sev == 18:
+		return syslogCrit
+	case sev == 17:
+		return syslogErr
+	case sev >= 13:
+		return syslogWarning
+	case sev >= 10:
+		return syslogNotice
+	case sev == 9:
+		return syslogInfo
+	default:
+		return syslogDebug
+	}
+}
+
+// newSyslogHandler returns a handler writing to the syslog daemon at t,
+// connecting right away so configuration errors surface at startup, and the
+// sink to close on shutdown.
+func newSyslogHandler(t *syslogTarget, tag string, logger *slog.Logger) (slog.Handler, *syslogSink) {
+	sink := &syslogSink{target: *t, tag: tag, logger: logger}
+	sink.mu.Lock()
+	warn := sink.connect()
+	sink.mu.Unlock()
+	if warn != nil {
+		logger.Warn("syslog unavailable, dropping syslog records until it can be reached", "error", warn)
+	}
+
+	// The text handler formats the record; syslog adds its own timestamp and
+	// carries the severity in the priority, which the sink reads back from the
+	// level attribute at the start of each line.
+	handler := slog.NewTextHandler(sink, &slog.HandlerOptions{
+		Level: slog.LevelDebug - 4,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.TimeKey:
+				return slog.Attr{}
+			case slog.LevelKey:
+				return slog.Int(slog.LevelKey, syslogSeverity(a.Value.Any().(slog.Level)))
+			}
+			return a
+		},
+	})
+	return handler, sink
+}
+
+// syslogSink writes formatted records to the syslog daemon, reconnecting
+// after failures.
+type syslogSink struct {
+	target syslogTarget
+	tag    string
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	conn    syslogConn
+	retryAt time.Time
+	down    bool
+	dropped int
+	closed  bool
+}
+
+// syslogConn is a connection to the daemon; see dialSyslog.
+type syslogConn interface {
+	write(severity int, msg string) error
+	Close() error
+}
+
+// Write sends one line formatted by the text handler, "level=N msg=...".
+func (s *syslogSink) Write(p []byte) (int, error) {
+	rest, ok := bytes.CutPrefix(p, []byte(slog.LevelKey+"="))
+	if !ok || len(rest) < 2 {
+		return 0, errors.New("syslog: unexpected record format")
+	}
+	severity, msg := int(rest[0]-'0'), string(bytes.TrimSpace(rest[2:]))
+
+	s.mu.Lock()
+	warn := s.connect()
+	var recovered int
+	if s.conn != nil {
+		if err := s.conn.write(severity, msg); err != nil {
+			// log/syslog has already retried on a fresh connection.
+			_ = s.conn.Close()
+			s.conn, s.retryAt, s.down = nil, time.Now().Add(syslogRetryInterval), true
+			warn = err
+			s.dropped++
+		} else if s.down {
+			recovered, s.dropped, s.down = s.dropped, 0, false
+		}
+	} else {
+		s.dropped++
+	}
+	s.mu.Unlock()
+
+	// Logged without the lock: the internal logger may itself write here.
+	if warn != nil {
+		s.logger.Warn("syslog unavailable, dropping syslog records until it can be reached", "error", warn)
+	}
+	if recovered > 0 {
+		s.logger.Info("syslog reachable again", "dropped", recovered)
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the daemon. Records written afterwards are
+// dropped.
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// connect dials the daemon unless connected, closed or waiting to retry. It
+// returns the dial error the first time the daemon is found unreachable. s.mu
+// must be held.
+func (s *syslogSink) connect() error {
+	if s.conn != nil || s.closed || time.Now().Before(s.retryAt) {
+		return nil
+	}
+	conn, err := dialSyslog(s.target.network, s.target.addr, s.tag)
+	if err != nil {
+		s.retryAt = time.Now().Add(syslogRetryInterval)
+		if s.down {
+			return nil
+		}
+		s.down = true
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// fanoutHandler passes each record to all handlers that enable its level.
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// dialSyslog fails: log/syslog is not available on this platform.
+func dialSyslog(network, addr, tag string) (syslogConn, error) {
+	return nil, errors.New("syslog is not supported on " + runtime.GOOS)
+}
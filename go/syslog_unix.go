@@ -0,0 +1,39 @@
+//go:build unix
+
+package main
+
+import "log/syslog"
+
+// dialSyslog connects to the syslog daemon with the user facility.
+func dialSyslog(network, addr, tag string) (syslogConn, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return syslogWriter{w}, nil
+}
+
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+func (w syslogWriter) write(severity int, msg string) error {
+	switch severity {
+	case syslogEmerg:
+		return w.Emerg(msg)
+	case syslogAlert:
+		return w.Alert(msg)
+	case syslogCrit:
+		return w.Crit(msg)
+	case syslogErr:
+		return w.Err(msg)
+	case syslogWarning:
+		return w.Warning(msg)
+	case syslogNotice:
+		return w.Notice(msg)
+	case syslogInfo:
+		return w.Info(msg)
+	default:
+		return w.Debug(msg)
+	}
+}
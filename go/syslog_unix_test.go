@@ -0,0 +1,58 @@
+//go:build unix
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogConnectionClosedByCleanup(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	env := map[string]string{
+		"OTEL_TRACES_EXPORTER":  "none",
+		"OTEL_METRICS_EXPORTER": "none",
+		"OTEL_LOGS_EXPORTER":    "console",
+	}
+	cleanup, err := setup(context.Background(), "syslog-test", func(key string) string { return env[key] },
+		WithSyslogSink("tcp", ln.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		cleanup()
+		t.Fatalf("setup did not connect to syslog: %v", err)
+	}
+	defer conn.Close()
+
+	GetLogger().Info("checkout started")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		cleanup()
+		t.Fatalf("reading syslog message: %v", err)
+	}
+	if !strings.Contains(line, "checkout started") {
+		t.Errorf("syslog message = %q, want the logged message", line)
+	}
+
+	cleanup()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("read after cleanup = %v, want EOF from the closed connection", err)
+	}
+}
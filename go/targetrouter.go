@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// RoutedRecord describes a span, log record or metric data point to the
+// router of WithTargetPackageRouter.
+type RoutedRecord struct {
+	// Signal is "traces", "metrics" or "logs".
+	Signal string
+	// Name is the span name, the metric name or the log event name.
+	Name string
+	// Attributes are the attributes of the span, data point or log record,
+	// without the resource's. Log attribute values other than strings,
+	// numbers and booleans are given in their string form.
+	Attributes attribute.Set
+}
+
+// WithTargetPackageRouter sends telemetry to the Observe target package
+// route returns for it, so subsystems of one service can land in different
+// Observe apps. route is called for every span, log record and metric data
+// point before export, typically to read a routing attribute; an empty
+// result keeps the signal's default package ("Tracing", "Metrics" or
+// "Logs"). It must be fast and safe for concurrent use.
+//
+//	WithTargetPackageRouter(func(r RoutedRecord) string {
+//	    v, _ := r.Attributes.Value("subsystem")
+//	    return map[string]string{"billing": "Billing"}[v.AsString()]
+//	})
+//
+// As each request carries a single x-observe-target-package header, an
+// OTLP exporter is created per signal and target package on first use, and
+// every batch is split into one request per package. Each routed package
+// thus adds an export request per batch and signal, plus the routing work
+// of building an attribute set for each span and log record. The exporters
+// share their HTTP connections, but many packages mean many small requests;
+// keep the set of packages small and fixed. Only OTLP exporters are routed,
+// and WithTargetPackageAttribute keeps reporting the default package.
+func WithTargetPackageRouter(route func(RoutedRecord) string) Option {
+	return func(c *config) {
+		c.targetPackageRouter = route
+	}
+}
+
+// withTargetPackageHeader returns a copy of headers with the target package
+// header set to targetPackage.
+func withTargetPackageHeader(headers map[string]string, targetPackage string) map[string]string {
+	headers = maps.Clone(headers)
+	headers["x-observe-target-package"] = targetPackage
+	return headers
+}
+
+// routedExporters holds the default exporter of a signal and those created
+// for routed target packages.
+type routedExporters[E any] struct {
+	def    E
+	create func(targetPackage string) (E, error)
+
+	mu        sync.Mutex
+	byPackage map[string]E
+}
+
+// get returns the exporter for targetPackage, creating it on first use.
+func (s *routedExporters[E]) get(targetPackage string) (E, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.byPackage[targetPackage]; ok {
+		return e, nil
+	}
+	e, err := s.create(targetPackage)
+	if err != nil {
+		return e, err
+	}
+	if s.byPackage == nil {
+		s.byPackage = map[string]E{}
+	}
+	s.byPackage[targetPackage] = e
+	return e, nil
+}
+
+// all returns the default exporter and all created ones.
+func (s *routedExporters[E]) all() []E {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := []E{s.def}
+	for _, e := range s.byPackage {
+		all = append(all, e)
+	}
+	return all
+}
+
+// routeFor wraps route to return "" for the default package of signal, so
+// batches are split once per exporter.
+func routeFor(route func(RoutedRecord) string, signal string) func(RoutedRecord) string {
+	return func(r RoutedRecord) string {
+		if targetPackage := route(r); targetPackage != targetPackages[signal] {
+			return targetPackage
+		}
+		return ""
+	}
+}
+
+// routeExport sends each part of a split batch with the exporter of its
+// target package, the default one for the empty package.
+func routeExport[E, B any](s *routedExporters[E], parts map[string]B, export func(E, B) error) error {
+	var errs []error
+	for targetPackage, part := range parts {
+		e := s.def
+		if targetPackage != "" {
+			var err error
+			if e, err = s.get(targetPackage); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		errs = append(errs, export(e, part))
+	}
+	return errors.Join(errs...)
+}
+
+// routingSpanExporter splits span batches by target package.
+type routingSpanExporter struct {
+	route     func(RoutedRecord) string
+	exporters *routedExporters[sdktrace.SpanExporter]
+}
+
+func newRoutingSpanExporter(route func(RoutedRecord) string, def sdktrace.SpanExporter, create func(string) (sdktrace.SpanExporter, error)) routingSpanExporter {
+	return routingSpanExporter{route: routeFor(route, signalTraces), exporters: &routedExporters[sdktrace.SpanExporter]{def: def, create: create}}
+}
+
+func (e routingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	parts := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range spans {
+		targetPackage := e.route(RoutedRecord{Signal: signalTraces, Name: s.Name(), Attributes: attribute.NewSet(s.Attributes()...)})
+		parts[targetPackage] = append(parts[targetPackage], s)
+	}
+	return routeExport(e.exporters, parts, func(exp sdktrace.SpanExporter, spans []sdktrace.ReadOnlySpan) error {
+		return exp.ExportSpans(ctx, spans)
+	})
+}
+
+func (e routingSpanExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exp := range e.exporters.all() {
+		errs = append(errs, exp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// routingLogExporter splits log batches by target package.
+type routingLogExporter struct {
+	route     func(RoutedRecord) string
+	exporters *routedExporters[sdklog.Exporter]
+}
+
+func newRoutingLogExporter(route func(RoutedRecord) string, def sdklog.Exporter, create func(string) (sdklog.Exporter, error)) routingLogExporter {
+	return routingLogExporter{route: routeFor(route, signalLogs), exporters: &routedExporters[sdklog.Exporter]{def: def, create: create}}
+}
+
+func (e routingLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	parts := map[string][]sdklog.Record{}
+	for i := range records {
+		targetPackage := e.route(RoutedRecord{Signal: signalLogs, Name: records[i].EventName(), Attributes: logAttributeSet(&records[i])})
+		parts[targetPackage] = append(parts[targetPackage], records[i])
+	}
+	return routeExport(e.exporters, parts, func(exp sdklog.Exporter, records []sdklog.Record) error {
+		return exp.Export(ctx, records)
+	})
+}
+
+func (e routingLogExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exp := range e.exporters.all() {
+		errs = append(errs, exp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (e routingLogExporter) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, exp := range e.exporters.all() {
+		errs = append(errs, exp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// logAttributeSet converts the attributes of r for routing.
+func logAttributeSet(r *sdklog.Record) attribute.Set {
+	attrs := make([]attribute.KeyValue, 0, r.AttributesLen())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		switch v := kv.Value; v.Kind() {
+		case log.KindString:
+			attrs = append(attrs, attribute.String(kv.Key, v.AsString()))
+		case log.KindInt64:
+			attrs = append(attrs, attribute.Int64(kv.Key, v.AsInt64()))
+		case log.KindFloat64:
+			attrs = append(attrs, attribute.Float64(kv.Key, v.AsFloat64()))
+		case log.KindBool:
+			attrs = append(attrs, attribute.Bool(kv.Key, v.AsBool()))
+		default:
+			attrs = append(attrs, attribute.String(kv.Key, v.String()))
+		}
+		return true
+	})
+	return attribute.NewSet(attrs...)
+}
+
+// routingMetricExporter splits metric exports by target package, down to
+// the data points of each metric.
+type routingMetricExporter struct {
+	sdkmetric.Exporter // the default exporter, for temporality and aggregation
+	route              func(RoutedRecord) string
+	exporters          *routedExporters[sdkmetric.Exporter]
+}
+
+func newRoutingMetricExporter(route func(RoutedRecord) string, def sdkmetric.Exporter, create func(string) (sdkmetric.Exporter, error)) routingMetricExporter {
+	return routingMetricExporter{Exporter: def, route: routeFor(route, signalMetrics), exporters: &routedExporters[sdkmetric.Exporter]{def: def, create: create}}
+}
+
+func (e routingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	parts := map[string]*metricdata.ResourceMetrics{}
+	for _, sm := range rm.ScopeMetrics {
+		scopes := map[string]*metricdata.ScopeMetrics{}
+		for _, m := range sm.Metrics {
+			route := func(set attribute.Set) string {
+				return e.route(RoutedRecord{Signal: signalMetrics, Name: m.Name, Attributes: set})
+			}
+			for targetPackage, data := range splitMetricData(m.Data, route) {
+				scope, ok := scopes[targetPackage]
+				if !ok {
+					scope = &metricdata.ScopeMetrics{Scope: sm.Scope}
+					scopes[targetPackage] = scope
+				}
+				part := m
+				part.Data = data
+				scope.Metrics = append(scope.Metrics, part)
+			}
+		}
+		for targetPackage, scope := range scopes {
+			part, ok := parts[targetPackage]
+			if !ok {
+				part = &metricdata.ResourceMetrics{Resource: rm.Resource}
+				parts[targetPackage] = part
+			}
+			part.ScopeMetrics = append(part.ScopeMetrics, *scope)
+		}
+	}
+	return routeExport(e.exporters, parts, func(exp sdkmetric.Exporter, rm *metricdata.ResourceMetrics) error {
+		return exp.Export(ctx, rm)
+	})
+}
+
+func (e routingMetricExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exp := range e.exporters.all() {
+		errs = append(errs, exp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (e routingMetricExporter) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, exp := range e.exporters.all() {
+		errs = append(errs, exp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// splitMetricData splits the data points of a metric by target package.
+// Aggregations without data points are kept with the default package.
+func splitMetricData(data metricdata.Aggregation, route func(attribute.Set) string) map[string]metricdata.Aggregation {
+	parts := map[string]metricdata.Aggregation{}
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		for p, points := range splitPoints(d.DataPoints, dataPointAttrs[int64], route) {
+			parts[p] = metricdata.Gauge[int64]{DataPoints: points}
+		}
+	case metricdata.Gauge[float64]:
+		for p, points := range splitPoints(d.DataPoints, dataPointAttrs[float64], route) {
+			parts[p] = metricdata.Gauge[float64]{DataPoints: points}
+		}
+	case metricdata.Sum[int64]:
+		for p, points := range splitPoints(d.DataPoints, dataPointAttrs[int64], route) {
+			parts[p] = metricdata.Sum[int64]{DataPoints: points, Temporality: d.Temporality, IsMonotonic: d.IsMonotonic}
+		}
+	case metricdata.Sum[float64]:
+		for p, points := range splitPoints(d.DataPoints, dataPointAttrs[float64], route) {
+			parts[p] = metricdata.Sum[float64]{DataPoints: points, Temporality: d.Temporality, IsMonotonic: d.IsMonotonic}
+		}
+	case metricdata.Histogram[int64]:
+		for p, points := range splitPoints(d.DataPoints, histogramAttrs[int64], route) {
+			parts[p] = metricdata.Histogram[int64]{DataPoints: points, Temporality: d.Temporality}
+		}
+	case metricdata.Histogram[float64]:
+		for p, points := range splitPoints(d.DataPoints, histogramAttrs[float64], route) {
+			parts[p] = metricdata.Histogram[float64]{DataPoints: points, Temporality: d.Temporality}
+		}
+	case metricdata.ExponentialHistogram[int64]:
+		for p, points := range splitPoints(d.DataPoints, expHistogramAttrs[int64], route) {
+			parts[p] = metricdata.ExponentialHistogram[int64]{DataPoints: points, Temporality: d.Temporality}
+		}
+	case metricdata.ExponentialHistogram[float64]:
+		for p, points := range splitPoints(d.DataPoints, expHistogramAttrs[float64], route) {
+			parts[p] = metricdata.ExponentialHistogram[float64]{DataPoints: points, Temporality: d.Temporality}
+		}
+	case metricdata.Summary:
+		for p, points := range splitPoints(d.DataPoints, summaryAttrs, route) {
+			parts[p] = metricdata.Summary{DataPoints: points}
+		}
+	}
+	if len(parts) == 0 {
+		parts[""] = data
+	}
+	return parts
+}
+
+// splitPoints groups points by the target package of their attributes.
+func splitPoints[P any](points []P, attrs func(*P) *attribute.Set, route func(attribute.Set) string) map[string][]P {
+	parts := map[string][]P{}
+	for i := range points {
+		targetPackage := route(*attrs(&points[i]))
+		parts[targetPackage] = append(parts[targetPackage], points[i])
+	}
+	return parts
+}
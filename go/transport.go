@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signal names used to label the exporters' own telemetry.
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+)
+
+// selfScopeName is the instrumentation scope used for telemetry this setup
+// emits about itself (export health, rejected records, ...).
+const selfScopeName = "otel-setup"
+
+// defaultExportTimeout matches the OTLP exporters' default request timeout.
+const defaultExportTimeout = 10 * time.Second
+
+// newExporterClient builds the HTTP client used by the OTLP exporter of the
+// given signal. The transport is wrapped so export responses can be inspected.
+// Signals exporting to the same host share one base transport, and with it
+// the connection pool, so a service keeps one keep-alive connection to the
+// collector instead of one per signal. The probe client shares the
+// transport up to the request as the collector gets it, without the
+// wrappers that record export health, latency and debug output.
+func newExporterClient(signal, endpoint string, cfg *config) (client, probe *http.Client) {
+	client = &http.Client{Timeout: defaultExportTimeout}
+	var rt http.RoundTripper
+	if cfg.httpClient != nil {
+		// Copy the caller's client so its settings are kept but its transport
+		// can be wrapped without affecting other users of it.
+		*client = *cfg.httpClient
+		rt = client.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+	} else {
+		rt = sharedTransport(cfg, endpoint)
+	}
+
+	// Innermost, so the signature covers the request as sent
+	if cfg.requestSigner != nil {
+		rt = signingTransport{base: rt, sign: cfg.requestSigner}
+	}
+	if cfg.compression {
+		rt = newGzipTransport(rt, cfg.compressionLevel)
+	}
+	if cfg.noAuth {
+		rt = noAuthTransport{base: rt}
+	}
+	// Outside compression, so the file gets the request as encoded by the
+	// exporter
+	if f, ok := cfg.exportFiles[signal]; ok {
+		ft := &fileTransport{cfg: f}
+		if f.network {
+			ft.base = rt
+		}
+		rt = ft
+	}
+	// Outside the file, so it records the request as the collector gets it
+	if len(cfg.droppedEnvResourceKeys) > 0 {
+		rt = resourceFilterTransport{base: rt, signal: signal, keys: cfg.droppedEnvResourceKeys}
+	}
+	probe = new(http.Client)
+	*probe = *client
+	probe.Transport = rt
+	rt = firstExportTransport{base: rt}
+	rt = newLatencyTransport(rt, signal, cfg.logger)
+	rt = newPartialSuccessTransport(rt, signal, cfg.logger, cfg.partialSuccessLogLevel, cfg.partialSuccessRetries)
+	if cfg.breakerFailures > 0 {
+		rt = newCircuitBreakerTransport(rt, signal, cfg.logger, cfg.breakerFailures, cfg.breakerCooldown)
+	}
+	if cfg.debugExport {
+		rt = &debugTransport{base: rt, signal: signal, logger: cfg.logger}
+	}
+
+	client.Transport = rt
+	return client, probe
+}
+
+// sharedTransport returns the base transport for exports to endpoint,
+// creating it for the first signal using that scheme and host.
+func sharedTransport(cfg *config, endpoint string) *http.Transport {
+	var key string
+	if u, err := url.Parse(endpoint); err == nil {
+		key = u.Scheme + "://" + u.Host
+	}
+	if base, ok := cfg.transports[key]; ok {
+		return base
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.unixSocket != "" {
+		base.DialContext = unixSocketDialer(cfg.unixSocket)
+	} else if cfg.srv != nil {
+		base.DialContext = cfg.srv.dialer(cfg.logger)
+	}
+	if cfg.http2 {
+		base.Protocols = http2Protocols()
+	}
+	if cfg.transports == nil {
+		cfg.transports = map[string]*http.Transport{}
+	}
+	cfg.transports[key] = base
+	return base
+}
+
+// WithHTTPClient makes the exporters of all signals send through client,
+// e.g. to share its connection pool, proxy or TLS settings. Its transport is
+// wrapped, not replaced, so the exporter headers (including the bearer
+// token) and this setup's export metrics and options still apply. The client
+// is copied, so the caller's client is not modified; its Timeout applies to
+// each export request. WithUnixSocket, WithSRVEndpoint and WithHTTP2
+// configure the default transport and are ignored with a custom client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithUnixSocket sends all OTLP HTTP exports over the Unix domain socket at
+// path instead of TCP. The host and port of the configured endpoint are
+// ignored, but its scheme and the request paths still apply, so use an
+// http:// endpoint unless the listener on the socket terminates TLS. Only the
+// OTLP/HTTP exporters used by this setup are supported; OTLP/gRPC would need
+// a custom dialer on the gRPC connection instead.
+func WithUnixSocket(path string) Option {
+	return func(c *config) {
+		c.unixSocket = path
+	}
+}
+
+// WithHTTP2 exports over HTTP/2 only. For http:// endpoints this is HTTP/2
+// cleartext with prior knowledge (h2c), so the collector must accept h2c; for
+// https:// endpoints HTTP/2 is negotiated over TLS. HTTP/2 multiplexes
+// concurrent exports over a single connection instead of opening one
+// HTTP/1.1 connection per in-flight request, which helps when many batches
+// are exported at once.
+func WithHTTP2() Option {
+	return func(c *config) {
+		c.http2 = true
+	}
+}
+
+// http2Protocols restricts a transport to HTTP/2, sending http:// requests
+// as unencrypted HTTP/2 without an upgrade.
+func http2Protocols() *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
+
+// unixSocketDialer returns a DialContext function that connects to the Unix
+// domain socket at path regardless of the requested address.
+func unixSocketDialer(path string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// noAuthTransport removes any Authorization header from export requests.
+type noAuthTransport struct {
+	base http.RoundTripper
+}
+
+func (t noAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		req = req.Clone(req.Context())
+		req.Header.Del("Authorization")
+	}
+	return t.base.RoundTrip(req)
+}
+
+// partialSuccessTransport surfaces OTLP partial-success responses, where the
+// backend accepted the request but rejected some of its records.
+// The response only carries a count and a message, not which records were
+// rejected, so they cannot be retried selectively: the whole request is
+// re-sent up to retries times, then the rejection is reported.
+type partialSuccessTransport struct {
+	base     http.RoundTripper
+	signal   string
+	logger   *slog.Logger
+	level    slog.Level
+	retries  int
+	rejected metric.Int64Counter
+}
+
+func newPartialSuccessTransport(base http.RoundTripper, signal string, logger *slog.Logger, level slog.Level, retries int) *partialSuccessTransport {
+	// The global meter delegates to the SDK meter provider once it is installed,
+	// so the counter can be created before metrics are set up.
+	rejected, err := otel.Meter(selfScopeName).Int64Counter("otel.exporter.rejected",
+		metric.WithDescription("Records rejected by the backend in OTLP partial-success responses."),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		logger.Error("failed to create rejected records counter", "error", err)
+	}
+	return &partialSuccessTransport{
+		base:     base,
+		signal:   signal,
+		logger:   logger,
+		level:    level,
+		retries:  retries,
+		rejected: rejected,
+	}
+}
+
+func (t *partialSuccessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if t.retries > 0 {
+		var err error
+		if reqBody, req, err = readRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode/100 != 2 || resp.Body == nil {
+			return resp, err
+		}
+
+		// Buffer the body so the exporter can still read the response.
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		rejected, reason := parsePartialSuccess(t.signal, body)
+		if rejected == 0 {
+			return resp, nil
+		}
+		if attempt < t.retries {
+			t.logger.Log(req.Context(), t.level, "OTLP export partially rejected, retrying",
+				"signal", t.signal,
+				"rejected", rejected,
+				"reason", reason,
+				"attempt", attempt+1)
+			continue
+		}
+		t.report(req.Context(), rejected, reason)
+		return resp, nil
+	}
+}
+
+func (t *partialSuccessTransport) report(ctx context.Context, rejected int64, reason string) {
+	t.logger.Log(ctx, t.level, "OTLP export partially rejected",
+		"signal", t.signal,
+		"rejected", rejected,
+		"reason", reason)
+	if t.rejected != nil {
+		t.rejected.Add(ctx, rejected, metric.WithAttributes(attribute.String("signal", t.signal)))
+	}
+}
+
+// parsePartialSuccess decodes an OTLP protobuf export response and returns
+// the number of rejected records and the reason given by the backend.
+func parsePartialSuccess(signal string, body []byte) (int64, string) {
+	if len(body) == 0 {
+		return 0, ""
+	}
+	switch signal {
+	case signalTraces:
+		var resp coltracepb.ExportTraceServiceResponse
+		if proto.Unmarshal(body, &resp) == nil {
+			ps := resp.GetPartialSuccess()
+			return ps.GetRejectedSpans(), ps.GetErrorMessage()
+		}
+	case signalMetrics:
+		var resp colmetricpb.ExportMetricsServiceResponse
+		if proto.Unmarshal(body, &resp) == nil {
+			ps := resp.GetPartialSuccess()
+			return ps.GetRejectedDataPoints(), ps.GetErrorMessage()
+		}
+	case signalLogs:
+		var resp collogspb.ExportLogsServiceResponse
+		if proto.Unmarshal(body, &resp) == nil {
+			ps := resp.GetPartialSuccess()
+			return ps.GetRejectedLogRecords(), ps.GetErrorMessage()
+		}
+	}
+	return 0, ""
+}
+
+// latencyTransport records the duration of every export request, labeled by
+// signal and HTTP status class. It only observes: responses and errors are
+// passed through unchanged so the exporter's retry logic is unaffected.
+type latencyTransport struct {
+	base     http.RoundTripper
+	signal   string
+	duration metric.Float64Histogram
+}
+
+func newLatencyTransport(base http.RoundTripper, signal string, logger *slog.Logger) *latencyTransport {
+	duration, err := otel.Meter(selfScopeName).Float64Histogram("otel.exporter.duration",
+		metric.WithDescription("Duration of OTLP export requests."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		logger.Error("failed to create export duration histogram", "error", err)
+	}
+	return &latencyTransport{
+		base:     base,
+		signal:   signal,
+		duration: duration,
+	}
+}
+
+func (t *latencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if t.duration != nil {
+		t.duration.Record(req.Context(), time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("signal", t.signal),
+			attribute.String("http.status_class", statusClass(resp, err)),
+		))
+	}
+	return resp, err
+}
+
+// statusClass returns "2xx", "4xx", ... for resp, or "error" if the request
+// failed without a response.
+func statusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
+
+// debugTransport logs every export attempt with its outcome and latency.
+type debugTransport struct {
+	base   http.RoundTripper
+	signal string
+	logger *slog.Logger
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, req, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	items := countRequestItems(t.signal, body, req.Header.Get("Content-Encoding"))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	latency := time.Since(start)
+
+	if err != nil {
+		t.logger.InfoContext(req.Context(), "OTLP export attempt failed",
+			"signal", t.signal,
+			"items", items,
+			"endpoint", req.URL.String(),
+			"latency", latency,
+			"error", err)
+		return resp, err
+	}
+	t.logger.InfoContext(req.Context(), "OTLP export attempt",
+		"signal", t.signal,
+		"items", items,
+		"endpoint", req.URL.String(),
+		"status", resp.StatusCode,
+		"latency", latency)
+	return resp, nil
+}
+
+// readRequestBody reads the body of an export request and returns it along
+// with a copy of req whose body can be read again.
+func readRequestBody(req *http.Request) ([]byte, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, req, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, r, nil
+}
+
+// countRequestItems decodes an OTLP protobuf export request body and returns
+// the number of spans, metrics or log records it carries, or -1 if the body
+// cannot be decoded.
+func countRequestItems(signal string, body []byte, encoding string) int {
+	body, err := decodeBody(body, encoding)
+	if err != nil {
+		return -1
+	}
+
+	n := 0
+	switch signal {
+	case signalTraces:
+		var msg coltracepb.ExportTraceServiceRequest
+		if proto.Unmarshal(body, &msg) != nil {
+			return -1
+		}
+		for _, rs := range msg.GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				n += len(ss.GetSpans())
+			}
+		}
+	case signalMetrics:
+		var msg colmetricpb.ExportMetricsServiceRequest
+		if proto.Unmarshal(body, &msg) != nil {
+			return -1
+		}
+		for _, rm := range msg.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				n += len(sm.GetMetrics())
+			}
+		}
+	case signalLogs:
+		var msg collogspb.ExportLogsServiceRequest
+		if proto.Unmarshal(body, &msg) != nil {
+			return -1
+		}
+		for _, rl := range msg.GetResourceLogs() {
+			for _, sl := range rl.GetScopeLogs() {
+				n += len(sl.GetLogRecords())
+			}
+		}
+	}
+	return n
+}
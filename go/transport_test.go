@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordingTransport answers every request with an empty 200 response and
+// records the requests it received.
+type recordingTransport struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.reqs = append(rt.reqs, req)
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestBuildExporterOptionsSameForAllSignals(t *testing.T) {
+	rec := &recordingTransport{}
+	cfg := testConfig(t,
+		map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT":     "https://collector.example:4318",
+			"OTEL_EXPORTER_OTLP_BEARER_TOKEN": "secret",
+		},
+		WithHTTPClient(&http.Client{Timeout: 7 * time.Second, Transport: rec}),
+		WithCompressionLevel(1),
+	)
+
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs} {
+		exp := buildExporterOptions(cfg, signal, cfg.otlpEndpoint, cfg.bearerToken)
+		if exp.endpointURL != "https://collector.example:4318" {
+			t.Errorf("%s: endpoint = %s", signal, exp.endpointURL)
+		}
+		if exp.urlPath != "/v1/"+signal {
+			t.Errorf("%s: path = %s, want /v1/%s", signal, exp.urlPath, signal)
+		}
+		if got := exp.headers["x-observe-target-package"]; got != targetPackages[signal] {
+			t.Errorf("%s: target package = %q, want %q", signal, got, targetPackages[signal])
+		}
+		common := maps.Clone(exp.headers)
+		delete(common, "x-observe-target-package")
+		if want := map[string]string{"Authorization": "Bearer secret"}; !maps.Equal(common, want) {
+			t.Errorf("%s: headers = %v, want %v plus the target package", signal, common, want)
+		}
+		if exp.client.Timeout != 7*time.Second {
+			t.Errorf("%s: client timeout = %v, want the caller's 7s", signal, exp.client.Timeout)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, exp.endpointURL+exp.urlPath, strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := exp.client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", signal, err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(rec.reqs) != 3 {
+		t.Fatalf("caller's transport got %d requests, want one per signal", len(rec.reqs))
+	}
+	for _, req := range rec.reqs {
+		if got := req.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("%s: Content-Encoding = %q, want gzip for every signal", req.URL.Path, got)
+		}
+	}
+}
+
+func TestSharedTransportReusesConnectionsAcrossSignals(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		newConns int
+	)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	cfg := testConfig(t, map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL})
+	var reused []bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+	}
+	for _, signal := range []string{signalTraces, signalMetrics, signalLogs, signalTraces} {
+		exp := buildExporterOptions(cfg, signal, cfg.otlpEndpoint, cfg.bearerToken)
+		req, err := http.NewRequest(http.MethodPost, exp.endpointURL+exp.urlPath, strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		resp, err := exp.client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", signal, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if want := []bool{false, true, true, true}; !slices.Equal(reused, want) {
+		t.Errorf("connection reused = %v, want %v", reused, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if newConns != 1 {
+		t.Errorf("server accepted %d connections, want 1 shared by all signals", newConns)
+	}
+}
+
+func TestPartialSuccessRetry(t *testing.T) {
+	partial, err := proto.Marshal(&coltracepb.ExportTraceServiceResponse{
+		PartialSuccess: &coltracepb.ExportTracePartialSuccess{RejectedSpans: 2, ErrorMessage: "throttled"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		retries      int
+		rejections   int
+		wantRequests int
+		wantReported bool
+	}{
+		{"disabled", 0, 1, 1, true},
+		{"accepted on retry", 2, 1, 2, false},
+		{"rejected on every attempt", 2, 5, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				if len(bodies) <= tt.rejections {
+					w.Write(partial)
+				}
+			}))
+			defer srv.Close()
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+			client := &http.Client{Transport: newPartialSuccessTransport(http.DefaultTransport, signalTraces, logger, slog.LevelWarn, tt.retries)}
+			resp, err := client.Post(srv.URL+"/v1/traces", "application/x-protobuf", strings.NewReader("spans"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if len(bodies) != tt.wantRequests {
+				t.Errorf("collector got %d requests, want %d", len(bodies), tt.wantRequests)
+			}
+			for i, body := range bodies {
+				if body != "spans" {
+					t.Errorf("request %d body = %q, want the whole batch", i, body)
+				}
+			}
+			if got := strings.Contains(logs.String(), `msg="OTLP export partially rejected" `); got != tt.wantReported {
+				t.Errorf("rejection reported = %v, want %v:\n%s", got, tt.wantReported, logs.String())
+			}
+		})
+	}
+}
+
+func TestSharedTransportKeyedBySchemeAndHost(t *testing.T) {
+	cfg := newConfig()
+	base := sharedTransport(cfg, "http://collector:4318")
+	if sharedTransport(cfg, "http://collector:4318/v1/traces") != base {
+		t.Error("same scheme and host got a different transport")
+	}
+	if sharedTransport(cfg, "https://collector:4318") == base {
+		t.Error("https endpoint shares the transport of the http one")
+	}
+	if sharedTransport(cfg, "http://other:4318") == base {
+		t.Error("other host shares the transport")
+	}
+}
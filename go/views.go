@@ -0,0 +1,216 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// WithInstrumentRename installs a metric view that exports the instrument
+// named from under the name to, e.g. to align third-party instrumentation with
+// existing dashboards. from is matched against the instrument name by the
+// view's instrument selector and must be an exact name: the SDK refuses to
+// rename instruments selected with a wildcard.
+func WithInstrumentRename(from, to string) Option {
+	return WithInstrumentRenameUnit(from, to, "")
+}
+
+// WithInstrumentRenameUnit is like WithInstrumentRename but also replaces the
+// instrument's unit. An empty unit keeps the original one. Only the unit
+// label changes; recorded values are not converted.
+func WithInstrumentRenameUnit(from, to, unit string) Option {
+	return func(c *config) {
+		c.views = append(c.views, sdkmetric.NewView(
+			sdkmetric.Instrument{Name: from},
+			sdkmetric.Stream{Name: to, Unit: unit},
+		))
+	}
+}
+
+// openMetricsUnits maps UCUM units to the suffixes used in OpenMetrics
+// names. Units in braces, such as "{request}", are annotations and add no
+// suffix.
+var openMetricsUnits = map[string]string{
+	"d":    "days",
+	"h":    "hours",
+	"min":  "minutes",
+	"s":    "seconds",
+	"ms":   "milliseconds",
+	"us":   "microseconds",
+	"ns":   "nanoseconds",
+	"By":   "bytes",
+	"KiBy": "kibibytes",
+	"MiBy": "mebibytes",
+	"GiBy": "gibibytes",
+	"KBy":  "kilobytes",
+	"MBy":  "megabytes",
+	"GBy":  "gigabytes",
+	"m":    "meters",
+	"V":    "volts",
+	"A":    "amperes",
+	"J":    "joules",
+	"W":    "watts",
+	"g":    "grams",
+	"Cel":  "celsius",
+	"Hz":   "hertz",
+	"%":    "percent",
+	"1":    "ratio",
+}
+
+// WithOpenMetricsNaming exports every metric under an OpenMetrics-compatible
+// name so that existing Prometheus alert rules keep matching:
+//
+//   - characters other than letters, digits, "_" and ":" become "_",
+//     e.g. http.server.request.duration -> http_server_request_duration;
+//   - the unit is appended as a suffix, e.g. "s" -> _seconds,
+//     "By" -> _bytes, "1" -> _ratio; annotations like "{request}" add none;
+//   - counters (monotonic sums) get a _total suffix.
+//
+// Suffixes already present are not repeated. Only names change: values are
+// not converted, so instruments should already record base units (seconds
+// rather than milliseconds). Names set by WithInstrumentRename are
+// normalized too; if several views match an instrument, only the first one
+// registered applies.
+func WithOpenMetricsNaming() Option {
+	return func(c *config) {
+		c.openMetricsNaming = true
+	}
+}
+
+// defaultUCUMUnits maps unit strings commonly given to instruments to their
+// UCUM equivalents, as used by WithUCUMUnits. Units that are already valid
+// UCUM, such as "ms" or "By", are not listed and pass through unchanged.
+var defaultUCUMUnits = map[string]string{
+	"nanoseconds":  "ns",
+	"microseconds": "us",
+	"μs":           "us",
+	"milliseconds": "ms",
+	"millis":       "ms",
+	"seconds":      "s",
+	"sec":          "s",
+	"minutes":      "min",
+	"hours":        "h",
+	"days":         "d",
+	"bytes":        "By",
+	"B":            "By",
+	"KiB":          "KiBy",
+	"MiB":          "MiBy",
+	"GiB":          "GiBy",
+	"percent":      "%",
+	"ratio":        "1",
+	"requests":     "{request}",
+	"errors":       "{error}",
+	"connections":  "{connection}",
+	"messages":     "{message}",
+	"operations":   "{operation}",
+	"items":        "{item}",
+}
+
+// WithUCUMUnits rewrites instrument units to UCUM notation, as expected by
+// Observe, using the table in defaultUCUMUnits: e.g. "milliseconds" becomes
+// "ms" and "requests" becomes "{request}". Entries in overrides are added to
+// the table, replacing defaults for the same unit; map a unit to itself to
+// keep it. Units not in the table are exported unchanged. Only the unit
+// label changes; recorded values are not converted. Units set by
+// WithInstrumentRenameUnit are normalized too, and WithOpenMetricsNaming
+// derives its suffix from the normalized unit.
+func WithUCUMUnits(overrides map[string]string) Option {
+	return func(c *config) {
+		c.ucumUnits = maps.Clone(defaultUCUMUnits)
+		maps.Copy(c.ucumUnits, overrides)
+	}
+}
+
+// WithAttributeAllowlist keeps only the attribute keys listed for the
+// instrument named instrument and drops all others, to strictly bound
+// cardinality. Pass "*" as instrument for an allowlist that applies to every
+// instrument without one of its own. Calling it again for the same
+// instrument adds keys to its list.
+func WithAttributeAllowlist(instrument string, keys ...string) Option {
+	return func(c *config) {
+		if c.attributeAllowlists == nil {
+			c.attributeAllowlists = map[string][]attribute.Key{}
+		}
+		allowed := c.attributeAllowlists[instrument]
+		for _, k := range keys {
+			allowed = append(allowed, attribute.Key(k))
+		}
+		// An empty list is kept: it drops every attribute.
+		c.attributeAllowlists[instrument] = slices.Clip(allowed)
+	}
+}
+
+// metricViews returns the views to install on the meter provider. When
+// options other than renames need to apply to every instrument, all views are
+// combined into one, since the SDK exports an instrument once per matching
+// view.
+func metricViews(cfg *config) []sdkmetric.View {
+	if !cfg.openMetricsNaming && len(cfg.attributeAllowlists) == 0 && cfg.ucumUnits == nil {
+		return cfg.views
+	}
+	return []sdkmetric.View{combinedView(cfg)}
+}
+
+// combinedView returns a view that applies the first matching view of cfg,
+// or the instrument's own name, then the attribute allowlist, the UCUM unit
+// normalization and the OpenMetrics naming, if enabled.
+func combinedView(cfg *config) sdkmetric.View {
+	return func(inst sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+		stream := sdkmetric.Stream{Name: inst.Name, Description: inst.Description, Unit: inst.Unit}
+		for _, v := range cfg.views {
+			if s, ok := v(inst); ok {
+				stream = s
+				break
+			}
+		}
+
+		keys, ok := cfg.attributeAllowlists[inst.Name]
+		if !ok {
+			keys, ok = cfg.attributeAllowlists["*"]
+		}
+		if ok {
+			allow := attribute.NewAllowKeysFilter(keys...)
+			if prev := stream.AttributeFilter; prev != nil {
+				stream.AttributeFilter = func(kv attribute.KeyValue) bool { return prev(kv) && allow(kv) }
+			} else {
+				stream.AttributeFilter = allow
+			}
+		}
+
+		if unit, ok := cfg.ucumUnits[stream.Unit]; ok {
+			stream.Unit = unit
+		}
+		if cfg.openMetricsNaming {
+			stream.Name = openMetricsName(stream.Name, stream.Unit, inst.Kind)
+		}
+		return stream, true
+	}
+}
+
+// openMetricsName returns name converted to OpenMetrics conventions for an
+// instrument of the given unit and kind.
+func openMetricsName(name, unit string, kind sdkmetric.InstrumentKind) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name != "" && '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+
+	if suffix := openMetricsUnits[unit]; suffix != "" && !strings.HasSuffix(name, "_"+suffix) {
+		name += "_" + suffix
+	}
+	if kind == sdkmetric.InstrumentKindCounter || kind == sdkmetric.InstrumentKindObservableCounter {
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+	}
+	return name
+}